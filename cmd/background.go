@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+)
+
+// backgroundTasks runs functions in the background for the lifetime of the
+// application. All functions are stopped and waited for before the application
+// stops.
+type backgroundTasks struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newBackgroundTasks creates a new set of background tasks which are stopped
+// when the given context is marked done or `stop()` is called.
+func newBackgroundTasks(ctx context.Context) *backgroundTasks {
+	ctx, cancel := context.WithCancel(ctx)
+	return &backgroundTasks{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// run the function in a separate goroutine. The function must return as soon
+// as the context passed to it is marked done.
+func (bt *backgroundTasks) run(fn func(context.Context)) {
+	bt.wg.Add(1)
+	go func() {
+		defer bt.wg.Done()
+		fn(bt.ctx)
+	}()
+}
+
+// stop all background tasks and wait for them to return.
+func (bt *backgroundTasks) stop() {
+	bt.cancel()
+	bt.wg.Wait()
+}
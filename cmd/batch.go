@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"go.uber.org/zap"
+
+	"github.com/axiomhq/pkg/version"
+)
+
+// batchSummaryTimeout is the maximum time spent ingesting the batch summary.
+const batchSummaryTimeout = time.Second * 10
+
+// BatchProgress tracks the progress of a batch job. It is safe for concurrent
+// use.
+type BatchProgress struct {
+	processed uint64
+	errors    uint64
+}
+
+type batchProgressKey struct{}
+
+// Progress returns the progress tracker of the batch job. The context must be
+// the one passed to the `RunFunc`. If the application doesn't run in batch
+// mode, a tracker that is not reported anywhere is returned.
+func Progress(ctx context.Context) *BatchProgress {
+	if p, ok := ctx.Value(batchProgressKey{}).(*BatchProgress); ok {
+		return p
+	}
+	return new(BatchProgress)
+}
+
+// Add n to the number of processed items.
+func (p *BatchProgress) Add(n uint64) {
+	atomic.AddUint64(&p.processed, n)
+}
+
+// AddErrors adds n to the number of errors.
+func (p *BatchProgress) AddErrors(n uint64) {
+	atomic.AddUint64(&p.errors, n)
+}
+
+// Processed returns the number of processed items.
+func (p *BatchProgress) Processed() uint64 {
+	return atomic.LoadUint64(&p.processed)
+}
+
+// Errors returns the number of errors.
+func (p *BatchProgress) Errors() uint64 {
+	return atomic.LoadUint64(&p.errors)
+}
+
+// fields returns the progress as logger fields.
+func (p *BatchProgress) fields() []zap.Field {
+	return []zap.Field{
+		zap.Uint64("items_processed", p.Processed()),
+		zap.Uint64("errors", p.Errors()),
+	}
+}
+
+// reportProgress logs the progress of the batch job and reports it to the
+// service manager at the given interval. It blocks until the context is marked
+// done.
+func (p *BatchProgress) reportProgress(ctx context.Context, logger *zap.Logger, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		logger.Info("progress", p.fields()...)
+
+		status := fmt.Sprintf("processed %d items, %d errors", p.Processed(), p.Errors())
+		if err := Status(ctx, status); err != nil {
+			logger.Error("report progress", zap.Error(err))
+		}
+	}
+}
+
+// reportBatchSummary logs the summary of the batch job and, if a dataset is
+// given, ingests it into Axiom.
+func reportBatchSummary(logger *zap.Logger, client *axiom.Client, dataset, appName string, p *BatchProgress, res Result) {
+	duration := time.Since(res.StartTime)
+
+	fields := append(p.fields(),
+		zap.Duration("duration", duration),
+		zap.Uint8("exit_code", uint8(res.ExitCode)),
+	)
+	if res.Err != nil {
+		fields = append(fields, zap.Error(res.Err))
+	}
+	logger.Info("batch summary", fields...)
+
+	if dataset == "" {
+		return
+	}
+
+	event := axiom.Event{
+		axiom.TimestampField: time.Now(),
+		"app":                appName,
+		"start_time":         res.StartTime,
+		"duration_seconds":   duration.Seconds(),
+		"items_processed":    p.Processed(),
+		"errors":             p.Errors(),
+		"exit_code":          uint8(res.ExitCode),
+		"release":            version.Release(),
+		"revision":           version.Revision(),
+		"build_date":         version.BuildDateString(),
+		"build_user":         version.BuildUser(),
+		"go_version":         version.GoVersion(),
+	}
+	if res.Err != nil {
+		event["error"] = res.Err.Error()
+	}
+
+	// The run context might already be cancelled, so use a fresh one to
+	// deliver the summary.
+	ctx, cancel := context.WithTimeout(context.Background(), batchSummaryTimeout)
+	defer cancel()
+
+	if _, err := client.Datasets.IngestEvents(ctx, dataset, axiom.IngestOptions{}, event); err != nil {
+		logger.Error("ingest batch summary", zap.Error(err), zap.String("dataset", dataset))
+	}
+}
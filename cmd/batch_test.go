@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRun_BatchMode(t *testing.T) {
+	axiomOptions, eventCh := withTestIngestServer(t)
+
+	fn := func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		Progress(ctx).Add(3)
+		Progress(ctx).AddErrors(1)
+		return errors.New("partial failure")
+	}
+
+	res := RunE("backfill", fn,
+		axiomOptions,
+		WithBatchMode(time.Millisecond),
+		WithBatchSummaryDataset("jobs"),
+	)
+	assert.Equal(t, ExitInternal, res.ExitCode)
+
+	select {
+	case ingested := <-eventCh:
+		assert.Equal(t, "jobs", ingested.dataset)
+		assert.Equal(t, "backfill", ingested.event["app"])
+		assert.EqualValues(t, 3, ingested.event["items_processed"])
+		assert.EqualValues(t, 1, ingested.event["errors"])
+		assert.EqualValues(t, ExitInternal, ingested.event["exit_code"])
+		assert.Equal(t, "partial failure", ingested.event["error"])
+		assert.Contains(t, ingested.event, "release")
+	case <-time.After(time.Second * 5):
+		require.FailNow(t, "no batch summary ingested")
+	}
+}
+
+func TestProgress_NoBatchMode(t *testing.T) {
+	p := Progress(context.Background())
+	p.Add(1)
+	assert.EqualValues(t, 1, p.Processed())
+}
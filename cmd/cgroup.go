@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// defaultCgroupRoot is the path the cgroup filesystem is mounted at.
+const defaultCgroupRoot = "/sys/fs/cgroup"
+
+// cgroupUnlimitedMemory is the threshold above which a cgroup v1 memory limit
+// is considered unlimited. The kernel reports an unset limit as the maximum
+// value rounded down to the page size.
+const cgroupUnlimitedMemory = 1 << 62
+
+// cgroupLimits are the resource limits of a cgroup.
+type cgroupLimits struct {
+	// version of the cgroup hierarchy. It is zero if no cgroup filesystem was
+	// found.
+	version int
+	// cpu is the CPU quota in number of CPUs. It is zero if unlimited.
+	cpu float64
+	// memory is the memory limit in bytes. It is zero if unlimited.
+	memory int64
+}
+
+// readCgroupLimits reads the resource limits of the cgroup mounted at the
+// given root. It assumes the cgroup of the process is mounted at the root,
+// which is the case for containers running in their own cgroup namespace.
+func readCgroupLimits(root string) (cgroupLimits, error) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		return readCgroupV2Limits(root)
+	}
+	if _, err := os.Stat(filepath.Join(root, "memory")); err == nil {
+		return readCgroupV1Limits(root)
+	}
+	return cgroupLimits{}, nil
+}
+
+// readCgroupV2Limits reads the resource limits of a cgroup v2 hierarchy.
+func readCgroupV2Limits(root string) (cgroupLimits, error) {
+	limits := cgroupLimits{version: 2}
+
+	// The "cpu.max" file has the format "$MAX $PERIOD" where "$MAX" is "max"
+	// if the CPU usage is not limited.
+	if fields, err := readCgroupFile(filepath.Join(root, "cpu.max")); err != nil {
+		return limits, err
+	} else if len(fields) == 2 && fields[0] != "max" {
+		if limits.cpu, err = parseCPUQuota(fields[0], fields[1]); err != nil {
+			return limits, err
+		}
+	}
+
+	if fields, err := readCgroupFile(filepath.Join(root, "memory.max")); err != nil {
+		return limits, err
+	} else if len(fields) == 1 && fields[0] != "max" {
+		if limits.memory, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+			return limits, err
+		}
+	}
+
+	return limits, nil
+}
+
+// readCgroupV1Limits reads the resource limits of a cgroup v1 hierarchy.
+func readCgroupV1Limits(root string) (cgroupLimits, error) {
+	limits := cgroupLimits{version: 1}
+
+	// The CPU controller is usually co-mounted with the CPU accounting
+	// controller.
+	for _, dir := range []string{"cpu", "cpu,cpuacct"} {
+		quota, err := readCgroupFile(filepath.Join(root, dir, "cpu.cfs_quota_us"))
+		if err != nil {
+			return limits, err
+		} else if len(quota) != 1 {
+			continue
+		}
+		period, err := readCgroupFile(filepath.Join(root, dir, "cpu.cfs_period_us"))
+		if err != nil {
+			return limits, err
+		} else if len(period) != 1 {
+			continue
+		}
+
+		// A quota of "-1" means the CPU usage is not limited.
+		if quota[0] != "-1" {
+			if limits.cpu, err = parseCPUQuota(quota[0], period[0]); err != nil {
+				return limits, err
+			}
+		}
+		break
+	}
+
+	if fields, err := readCgroupFile(filepath.Join(root, "memory", "memory.limit_in_bytes")); err != nil {
+		return limits, err
+	} else if len(fields) == 1 {
+		if limits.memory, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+			return limits, err
+		} else if limits.memory >= cgroupUnlimitedMemory {
+			limits.memory = 0
+		}
+	}
+
+	return limits, nil
+}
+
+// readCgroupFile returns the whitespace separated fields of the cgroup file at
+// the given path. A file that doesn't exist yields no fields.
+func readCgroupFile(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(b)), nil
+}
+
+// parseCPUQuota returns the number of CPUs the given quota and period allow.
+func parseCPUQuota(quota, period string) (float64, error) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil {
+		return 0, err
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil {
+		return 0, err
+	} else if q <= 0 || p <= 0 {
+		return 0, nil
+	}
+	return q / p, nil
+}
+
+// runtimeTuning is the Go runtime configuration derived from cgroup limits.
+type runtimeTuning struct {
+	limits cgroupLimits
+	// gomaxprocs is the value to set `GOMAXPROCS` to. It is zero if it should
+	// be left untouched.
+	gomaxprocs int
+	// memoryLimit is the soft memory limit in bytes. It is zero if it should
+	// be left untouched.
+	memoryLimit int64
+}
+
+// newRuntimeTuning derives the Go runtime configuration from the given cgroup
+// limits. The memory limit leaves the given ratio of the cgroup memory limit
+// as headroom for memory not managed by the Go runtime.
+func newRuntimeTuning(limits cgroupLimits, headroom float64, numCPU int) runtimeTuning {
+	t := runtimeTuning{limits: limits}
+
+	if limits.cpu > 0 {
+		t.gomaxprocs = int(math.Ceil(limits.cpu))
+		if t.gomaxprocs > numCPU {
+			t.gomaxprocs = numCPU
+		}
+	}
+
+	if limits.memory > 0 {
+		t.memoryLimit = int64(float64(limits.memory) * (1 - headroom))
+	}
+
+	return t
+}
+
+// tuneRuntime configures the Go runtime according to the limits of the cgroup
+// mounted at the given root. Values explicitly configured using the
+// "GOMAXPROCS" and "GOMEMLIMIT" environment variables take precedence.
+func tuneRuntime(root string, headroom float64) (runtimeTuning, error) {
+	limits, err := readCgroupLimits(root)
+	if err != nil {
+		return runtimeTuning{}, err
+	}
+
+	t := newRuntimeTuning(limits, headroom, runtime.NumCPU())
+
+	if _, ok := os.LookupEnv("GOMAXPROCS"); ok {
+		t.gomaxprocs = 0
+	} else if t.gomaxprocs > 0 {
+		runtime.GOMAXPROCS(t.gomaxprocs)
+	}
+
+	if _, ok := os.LookupEnv("GOMEMLIMIT"); ok {
+		t.memoryLimit = 0
+	} else if t.memoryLimit > 0 && !setMemoryLimit(t.memoryLimit) {
+		t.memoryLimit = 0
+	}
+
+	return t, nil
+}
+
+// fields returns the effective runtime configuration as logger fields.
+func (t runtimeTuning) fields() []zap.Field {
+	return []zap.Field{
+		zap.Int("cgroup_version", t.limits.version),
+		zap.Float64("cgroup_cpu_limit", t.limits.cpu),
+		zap.Int64("cgroup_memory_limit", t.limits.memory),
+		zap.Int("gomaxprocs", runtime.GOMAXPROCS(0)),
+		zap.Int64("memory_limit", memoryLimit()),
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCgroupFS creates a fake cgroup filesystem with the given files.
+func writeCgroupFS(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	return root
+}
+
+func TestReadCgroupLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  cgroupLimits
+	}{
+		{
+			name: "none",
+			want: cgroupLimits{},
+		},
+		{
+			name: "v2",
+			files: map[string]string{
+				"cgroup.controllers": "cpu memory",
+				"cpu.max":            "150000 100000\n",
+				"memory.max":         "1073741824\n",
+			},
+			want: cgroupLimits{version: 2, cpu: 1.5, memory: 1 << 30},
+		},
+		{
+			name: "v2 unlimited",
+			files: map[string]string{
+				"cgroup.controllers": "cpu memory",
+				"cpu.max":            "max 100000\n",
+				"memory.max":         "max\n",
+			},
+			want: cgroupLimits{version: 2},
+		},
+		{
+			name: "v1",
+			files: map[string]string{
+				"cpu,cpuacct/cpu.cfs_quota_us":  "200000\n",
+				"cpu,cpuacct/cpu.cfs_period_us": "100000\n",
+				"memory/memory.limit_in_bytes":  "536870912\n",
+			},
+			want: cgroupLimits{version: 1, cpu: 2, memory: 1 << 29},
+		},
+		{
+			name: "v1 unlimited",
+			files: map[string]string{
+				"cpu/cpu.cfs_quota_us":         "-1\n",
+				"cpu/cpu.cfs_period_us":        "100000\n",
+				"memory/memory.limit_in_bytes": "9223372036854771712\n",
+			},
+			want: cgroupLimits{version: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readCgroupLimits(writeCgroupFS(t, tt.files))
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewRuntimeTuning(t *testing.T) {
+	tuning := newRuntimeTuning(cgroupLimits{version: 2, cpu: 1.5, memory: 1000}, 0.1, 8)
+	assert.Equal(t, 2, tuning.gomaxprocs)
+	assert.EqualValues(t, 900, tuning.memoryLimit)
+
+	tuning = newRuntimeTuning(cgroupLimits{version: 2, cpu: 16}, 0.1, 8)
+	assert.Equal(t, 8, tuning.gomaxprocs)
+	assert.Zero(t, tuning.memoryLimit)
+
+	tuning = newRuntimeTuning(cgroupLimits{}, 0.1, 8)
+	assert.Zero(t, tuning.gomaxprocs)
+	assert.Zero(t, tuning.memoryLimit)
+}
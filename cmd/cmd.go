@@ -2,61 +2,142 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"os/signal"
+	"runtime/debug"
 	"strconv"
+	"time"
 
 	"github.com/axiomhq/axiom-go/axiom"
 	"go.uber.org/zap"
 
+	"github.com/axiomhq/pkg/logctx"
+	"github.com/axiomhq/pkg/scheduler"
 	"github.com/axiomhq/pkg/version"
 )
 
 // RunFunc is implemented by the main packages and passed to the `Run` function
 // which takes care of signal handling, loading the runtime configuration and
 // setting up logging, the Axiom client, etc. It must block until the context is
-// marked done. Errors returned from the `RunFunc` should be created using the
-// `Error()` function.
+// marked done, unless the application runs in batch mode. The logger is also
+// carried by the context and can be read using `logctx.From()`. Errors returned
+// from the `RunFunc` should be created using the `Error()` function.
 type RunFunc func(context.Context, *zap.Logger, *axiom.Client) error
 
 // Run the named app with the given `RunFunc`. Additionally, options can be
-// passed to configure the behaviour of the bootstrapping process.
+// passed to configure the behaviour of the bootstrapping process. If the
+// application doesn't exit gracefully, `Run` exits the process with the
+// appropriate exit code.
 func Run(appName string, fn RunFunc, options ...Option) {
-	if code := run(appName, fn, options...); code != exitOK {
-		code.exit()
+	if res := RunE(appName, fn, options...); res.ExitCode != ExitOK {
+		res.ExitCode.exit()
 	}
 }
 
-func run(appName string, fn RunFunc, options ...Option) exitCode {
+// RunE is like `Run` but returns the result of the run instead of exiting the
+// process. This allows embedding the application into a larger program and
+// leaves the decision on how to exit to the caller.
+func RunE(appName string, fn RunFunc, options ...Option) (res Result) {
+	res.StartTime = time.Now()
+	defer func() { res.Duration = time.Since(res.StartTime) }()
+
+	// Time the startup phases. If bootstrapping fails, the failed phase is the
+	// last one reported. The configured slow phase threshold applies once the
+	// options are applied.
+	phases := newPhaseTimer(defaultSlowPhaseThreshold)
+	phases.begin("options", res.StartTime)
+	defer func() {
+		phases.end()
+		res.Phases = phases.phases
+	}()
+
 	// Setup the default config and apply the supplied options.
 	cfg := &config{
-		loggerOptions: DefaultLoggerOptions(),
-		exitSignals:   DefaultExitSignals(),
+		loggerOptions:      DefaultLoggerOptions(),
+		exitSignals:        DefaultExitSignals(),
+		cgroupRoot:         defaultCgroupRoot,
+		invocation:         defaultInvocation(),
+		slowPhaseThreshold: defaultSlowPhaseThreshold,
 	}
 	for _, option := range options {
 		if err := option(cfg); err != nil {
-			return exitConfig
+			log.Printf("invalid option: %v", err)
+			return res.withError(ExitConfig, err)
+		}
+	}
+	if err := validateSignalHandlers(cfg); err != nil {
+		log.Printf("invalid option: %v", err)
+		return res.withError(ExitConfig, err)
+	}
+	phases.threshold = cfg.slowPhaseThreshold
+
+	// If enabled, supervise the `RunFunc`.
+	if cfg.supervisor != nil {
+		fn = cfg.supervisor.supervise(fn)
+	}
+
+	// Resolve environment variables, scoped to the application if enabled.
+	env := &cfg.invocation.env
+	if cfg.appEnvPrefix {
+		env.prefix = appEnvPrefix(appName)
+	}
+	cfg.axiomOptions = append(env.axiomOptions(), cfg.axiomOptions...)
+
+	if cfg.hiddenCommands || cfg.commandLine != nil {
+		phases.begin("command_line", time.Now())
+	}
+
+	// If enabled, run the hidden commands which generate shell completion
+	// scripts and the man page instead of the application.
+	if cfg.hiddenCommands {
+		if ok, err := runHiddenCommand(cfg.invocation.Stdout, newCommandSpec(appName, cfg), cfg.invocation.Args); ok {
+			if err != nil {
+				log.Print(err)
+				return res.withError(ExitConfig, err)
+			}
+			return res
 		}
 	}
 
+	// Parse the command line flags, if declared. The flag set reports invalid
+	// arguments itself.
+	if set := cfg.commandLine; set != nil {
+		if err := set.Parse(cfg.invocation.Args); errors.Is(err, flag.ErrHelp) {
+			return res
+		} else if err != nil {
+			return res.withError(ExitConfig, err)
+		}
+		cfg.invocation.Args = set.Args()
+	}
+
 	// Set up logger.
+	phases.begin("logger", time.Now())
 	var (
 		logger *zap.Logger
+		locks  []*lockFile
 		err    error
 	)
 
-	if v, _ := strconv.ParseBool(os.Getenv("DEBUG")); v {
+	if v, _ := strconv.ParseBool(env.getenv("DEBUG")); v {
 		logger, err = zap.NewDevelopment(cfg.loggerOptions...)
 	} else {
 		logger, err = zap.NewProduction(cfg.loggerOptions...)
 	}
 	if err != nil {
 		log.Printf("failed to create logger: %v", err)
-		return exitConfig
+		return res.withError(ExitConfig, err)
 	}
 	defer func() {
+		// Release the locks in reverse order of acquisition.
+		for i := len(locks) - 1; i >= 0; i-- {
+			if releaseErr := locks[i].release(); releaseErr != nil {
+				logger.Error("release lock", zap.Error(releaseErr), zap.String("path", locks[i].path))
+			}
+		}
+
 		logger.Warn("stopped")
 
 		// HINT(lukasmalkmus): Ignore error because of
@@ -66,57 +147,412 @@ func run(appName string, fn RunFunc, options ...Option) exitCode {
 
 	// Add application name to the logger
 	logger = logger.Named(appName)
+	phases.setLogger(logger)
 
 	// Log version information.
-	logger.Info("starting",
+	startingFields := []zap.Field{
 		zap.String("release", version.Release()),
 		zap.String("revision", version.Revision()),
 		zap.String("build_date", version.BuildDateString()),
 		zap.String("build_user", version.BuildUser()),
 		zap.String("go_version", version.GoVersion()),
-	)
+	}
+
+	// If enabled, configure the Go runtime according to the container limits
+	// and log the chosen values alongside the version information.
+	if cfg.containerTuning {
+		phases.begin("runtime_tuning", time.Now())
+		if tuning, tuneErr := tuneRuntime(cfg.cgroupRoot, cfg.memoryHeadroom); tuneErr != nil {
+			logger.Warn("read cgroup limits", zap.Error(tuneErr))
+		} else {
+			startingFields = append(startingFields, tuning.fields()...)
+		}
+	}
+
+	// If configured, set the resource limits and log the effective values.
+	if len(cfg.rlimits) > 0 {
+		phases.begin("rlimits", time.Now())
+	}
+	for _, l := range cfg.rlimits {
+		soft, hard, rlimitErr := setRlimit(l.Resource, l.Value)
+		if rlimitErr != nil {
+			logger.Warn("set rlimit", zap.Error(rlimitErr), zap.Stringer("resource", l.Resource))
+			continue
+		}
+		startingFields = append(startingFields, rlimitFields(l.Resource, soft, hard)...)
+	}
+
+	// Log the start of the application and, if enabled, the values of the
+	// environment variables and where they were found.
+	phases.begin("starting", time.Now())
+	if env.prefix != "" {
+		names := append(append([]string{"DEBUG"}, axiomEnvVars...), cfg.requiredEnvVars...)
+		for _, name := range cfg.axiomProfiles {
+			prefix := profileEnvPrefix(name)
+			names = append(names, prefix+"URL", prefix+"TOKEN", prefix+"ORG_ID")
+		}
+		if cfg.flags != nil {
+			for _, v := range cfg.flags.Values() {
+				names = append(names, cfg.flags.EnvName(v.Name))
+			}
+		}
+		startingFields = append(startingFields,
+			zap.String("env_prefix", env.prefix),
+			env.field(names...),
+		)
+	}
+
+	logger.Info("starting", startingFields...)
+
+	// If enabled, report lifecycle events. They are delivered once the Axiom
+	// client is created and flushed when the application stops. A panic of the
+	// `RunFunc` is reported as a crash before it is propagated.
+	var lifecycle *lifecycleReporter
+	if cfg.lifecycleDataset != "" {
+		lifecycle = newLifecycleReporter(logger, appName, cfg.lifecycleDataset,
+			cfg.lifecycleTimeout, res.StartTime)
+		defer func() {
+			if r := recover(); r != nil {
+				lifecycle.panicked(r, debug.Stack())
+				lifecycle.close()
+				panic(r)
+			}
+			lifecycle.stopped(res)
+			lifecycle.close()
+		}()
+		lifecycle.report("starting", nil)
+	}
 
 	// Make sure the required environment variables are set.
-	for _, env := range cfg.requiredEnvVars {
-		if os.Getenv(env) == "" {
-			logger.Error("missing environment variable", zap.String("name", env))
-			return exitConfig
+	phases.begin("env", time.Now())
+	for _, name := range cfg.requiredEnvVars {
+		if env.getenv(name) == "" {
+			logger.Error("missing environment variable", zap.String("name", name))
+			return res.withError(ExitConfig, fmt.Errorf("missing environment variable %q", name))
+		}
+	}
+
+	// Make sure this is the only running instance and write the PID file.
+	phases.begin("locks", time.Now())
+	for _, l := range []struct {
+		path     string
+		writePID bool
+	}{
+		{cfg.instanceLock, false},
+		{cfg.pidFile, true},
+	} {
+		if l.path == "" {
+			continue
+		}
+
+		var lock *lockFile
+		if lock, err = acquireLockFile(l.path, l.writePID); err != nil {
+			logger.Error("acquire lock", zap.Error(err), zap.String("path", l.path))
+			if _, ok := err.(*lockedError); ok {
+				return res.withError(ExitLocked, err)
+			}
+			return res.withError(ExitConfig, err)
+		} else if lock.stalePID > 0 {
+			logger.Warn("took over stale lock",
+				zap.String("path", l.path),
+				zap.Int("stale_pid", lock.stalePID),
+			)
 		}
+		locks = append(locks, lock)
 	}
 
-	// Listen for termination signals.
-	ctx, cancel := signal.NotifyContext(context.Background(), cfg.exitSignals...)
+	// Listen for termination signals and record the one that caused the
+	// shutdown, if any. If configured, the application drains before the
+	// context is marked done. Like the Go runtime does, the goroutines are
+	// dumped to the log before stopping on SIGQUIT.
+	phases.begin("signals", time.Now())
+	sigCtx, cancel := notifyContext(context.Background(), cfg.drainDelay, func(sig os.Signal) {
+		if sig == goroutineDumpSignal {
+			logger.Warn("goroutine dump", zap.String("goroutines", goroutineDump()))
+		}
+	}, cfg.exitSignals...)
 	defer cancel()
+	defer func() { res.Signal = sigCtx.signal() }()
+
+	ready := new(readiness)
+	var ctx context.Context = context.WithValue(sigCtx, readinessKey{}, ready)
+
+	// Make the logger available to everything that is passed the context,
+	// e.g. through `logctx.From()`.
+	ctx = logctx.WithLogger(ctx, logger)
+	ctx = context.WithValue(ctx, invocationKey{}, &cfg.invocation)
+
+	// Set up the notifier which reports the application state to the service
+	// manager, if there is any. An unreachable service manager doesn't prevent
+	// the application from running. It sets the variables unprefixed.
+	notifier, err := newNotifier(envResolver{lookupEnv: env.lookupEnv})
+	if err != nil {
+		logger.Warn("connect to service manager", zap.Error(err))
+	}
+	defer func() {
+		if closeErr := notifier.close(); closeErr != nil {
+			logger.Error("close service manager connection", zap.Error(closeErr))
+		}
+	}()
+	ctx = withNotifier(ctx, notifier)
+
+	// Background tasks run until the application stops.
+	background := newBackgroundTasks(ctx)
+	defer background.stop()
 
 	// Create the Axiom client.
+	phases.begin("axiom_client", time.Now())
 	client, err := axiom.NewClient(cfg.axiomOptions...)
 	if err != nil {
 		logger.Error("create axiom client", zap.Error(err))
-		return exitConfig
+		return res.withError(ExitConfig, err)
+	}
+	lifecycle.start(client)
+
+	// Create the Axiom clients of the named profiles.
+	clients, err := newClientRegistry(cfg.axiomProfiles, cfg.axiomConfigFile, *env)
+	if err != nil {
+		logger.Error("create axiom profile clients", zap.Error(err))
+		return res.withError(ExitConfig, err)
 	}
+	ctx = context.WithValue(ctx, clientRegistryKey{}, clients)
 
-	// If enabled, validate the credentials of the Axiom client.
+	// If enabled, validate the credentials of the Axiom clients.
 	if cfg.validateAxiomCredentials {
-		if err = client.ValidateCredentials(ctx); err != nil {
+		phases.begin("credentials", time.Now())
+		if err = client.ValidateCredentials(ctx); err == nil {
+			err = clients.validateCredentials(ctx)
+		}
+		if err != nil {
 			logger.Error("validate axiom credentials", zap.Error(err))
-			return exitConfig
+			return res.withError(ExitConfig, err)
 		}
 	}
 
-	logger.Info("started")
+	// Start the background tasks and check the remaining configuration.
+	phases.begin("preflight", time.Now())
+
+	// If enabled, start the liveness watchdog. Depending on its policy, it
+	// cancels the context passed to the `RunFunc`.
+	var (
+		liveness    *livenessWatchdog
+		healthCheck = cfg.healthCheck
+	)
+	if cfg.livenessDeadline > 0 {
+		var cancelRun context.CancelFunc
+		ctx, cancelRun = context.WithCancel(ctx)
+		defer cancelRun()
+
+		liveness = newLivenessWatchdog(logger, cfg.livenessPolicy,
+			cfg.livenessDeadline, cfg.heartbeatDeadlines, cancelRun)
+		ctx = context.WithValue(ctx, livenessWatchdogKey{}, liveness)
+		healthCheck = combineHealthChecks(healthCheck, liveness.healthy)
+		ready.healthCheck = liveness.healthy
+
+		background.run(liveness.run)
+	}
+
+	// Handle non-exit signals. If SIGQUIT is not an exit signal and has no
+	// handler, the goroutines are dumped to the log on it. If enabled, profile
+	// dumps are written on SIGUSR2.
+	var (
+		handlers = cfg.signalHandlers
+		dumper   *profileDumper
+	)
+	if sig := goroutineDumpSignal; sig != nil && !containsSignal(cfg.exitSignals, sig) && !hasSignalHandler(handlers, sig) {
+		handlers = append(handlers, signalHandler{sig, func(context.Context) {
+			logger.Warn("goroutine dump", zap.String("goroutines", goroutineDump()))
+		}})
+	}
+	if sig := profileDumpSignal; cfg.profileDumps && sig != nil {
+		dumper = newProfileDumper(logger, cfg.profileDumpDir, appName)
+		handlers = append(handlers, signalHandler{sig, dumper.start})
+		background.run(func(bgCtx context.Context) {
+			<-bgCtx.Done()
+			dumper.wait()
+		})
+	}
+	background.run(newSignalHandlers(logger, handlers).run)
+
+	// If configured, load the flags. They are watched for changes once the
+	// application started.
+	flagsLogger := logger.Named("flags")
+	if cfg.flags != nil {
+		cfg.flags.SetEnvLookup(func(name string) (string, bool) {
+			value, _, ok := env.lookup(name)
+			return value, ok
+		})
+		if flagsErr := cfg.flags.Load(); flagsErr != nil {
+			flagsLogger.Error("load flags", zap.Error(flagsErr))
+			return res.withError(ExitConfig, flagsErr)
+		}
+
+		values := cfg.flags.Values()
+		fields := make([]zap.Field, 0, len(values))
+		for _, v := range values {
+			fields = append(fields, zap.String(v.Name, v.Value))
+		}
+		flagsLogger.Info("loaded", fields...)
+	}
+
+	// If configured, create the scheduler. The jobs run once the application
+	// started.
+	var sched *scheduler.Scheduler
+	if len(cfg.jobs) > 0 {
+		var schedErr error
+		if sched, schedErr = scheduler.New(logger.Named("scheduler"), cfg.jobs...); schedErr != nil {
+			logger.Error("create scheduler", zap.Error(schedErr))
+			return res.withError(ExitConfig, schedErr)
+		}
+	}
+
+	// In batch mode, track the progress of the job.
+	progress := new(BatchProgress)
+	if cfg.batchMode {
+		ctx = context.WithValue(ctx, batchProgressKey{}, progress)
+		if cfg.batchProgressInterval > 0 {
+			background.run(func(bgCtx context.Context) {
+				progress.reportProgress(bgCtx, logger, cfg.batchProgressInterval)
+			})
+		}
+	}
+
+	// Call the hooks that run before the `RunFunc`. If one fails, the
+	// `AfterRun` hooks of the ones that succeeded are still called.
+	phases.begin("hooks", time.Now())
+	hooks := hookChain(append(registeredHooks(), cfg.hooks...))
+	hooksRun, err := hooks.beforeRun(ctx, logger, client)
+	if err != nil {
+		_ = hooks.afterRun(ctx, logger, client, hooksRun)
+		return res.withError(exitCodeOf(err), err)
+	}
+
+	// If enabled, restrict the process now that startup is done. Profile dumps
+	// must remain writable.
+	if cfg.hardening != nil {
+		phases.begin("hardening", time.Now())
+		var dumpDir string
+		if dumper != nil {
+			dumpDir = dumper.dir
+		}
+		policy := cfg.hardening.withDefaultPaths(cfg, dumpDir)
+
+		// A platform or binary that doesn't support hardening is only
+		// tolerated if the policy doesn't require it.
+		h, hardenErr := harden(policy)
+		switch {
+		case errors.Is(hardenErr, errHardeningUnsupported) && !policy.Required:
+			logger.Warn("hardening not supported, process is not restricted", zap.Error(hardenErr))
+		case hardenErr != nil:
+			logger.Error("harden process", zap.Error(hardenErr))
+			_ = hooks.afterRun(ctx, logger, client, hooksRun)
+			return res.withError(ExitConfig, hardenErr)
+		default:
+			if h.landlockABI == 0 {
+				logger.Warn("landlock not supported, filesystem access is not restricted")
+			}
+			logger.Info("hardened", h.fields(policy)...)
+		}
+	}
+
+	phases.end()
+	res.StartupDuration = time.Since(res.StartTime)
+	logger.Info("started",
+		zap.Duration("startup_duration", res.StartupDuration),
+		zap.Object("phases", phases.phases),
+	)
+	lifecycle.report("started", nil)
+
+	// Start the periodic work only now, so it never runs before the hooks
+	// prepared the application or outside of the restricted process: If
+	// enabled, report runtime statistics, watch the flags for changes and run
+	// the scheduled jobs.
+	if cfg.runtimeStatsInterval > 0 {
+		background.run(newRuntimeStatsReporter(logger, client,
+			cfg.runtimeStatsDataset, cfg.runtimeStatsInterval).run)
+	}
+	if cfg.flags != nil {
+		background.run(func(bgCtx context.Context) {
+			cfg.flags.Watch(bgCtx, flagsLogger)
+		})
+	}
+	if sched != nil {
+		background.run(sched.Run)
+	}
+
+	// Report readiness to the service manager, unless the application does
+	// that itself, and keep the watchdog happy. Shutdown is reported as soon
+	// as the drain phase begins, the context is marked done or the `RunFunc`
+	// returns.
+	if !cfg.manualReadiness {
+		ready.setReady()
+		if err = notifier.ready(); err != nil {
+			logger.Error("report readiness", zap.Error(err))
+		}
+	}
+	background.run(func(bgCtx context.Context) {
+		notifier.watchdog(bgCtx, logger, healthCheck)
+	})
+	background.run(func(bgCtx context.Context) {
+		select {
+		case <-bgCtx.Done():
+		case <-sigCtx.draining():
+			if cfg.drainDelay > 0 {
+				logger.Info("draining",
+					zap.Stringer("signal", sigCtx.signal()),
+					zap.Duration("drain_delay", cfg.drainDelay),
+				)
+			}
+		}
+		ready.drain()
+		lifecycle.stopping()
+		if stoppingErr := notifier.stopping(); stoppingErr != nil {
+			logger.Error("report stopping", zap.Error(stoppingErr))
+		}
+	})
+
+	background.run(func(bgCtx context.Context) {
+		select {
+		case <-bgCtx.Done():
+		case <-sigCtx.draining():
+			hooks.onSignal(ctx, logger, client, sigCtx.signal())
+		}
+	})
 
 	// Call the actual `RunFunc`. If the returned error was composed using
-	// `cmd.Error()`, it can be logged properly. If not, logging the error is
-	// done as well but with less context to it.
+	// `cmd.Error()` or the errors package, it can be logged properly. If not,
+	// logging the error is done as well but with less context to it.
 	if err = fn(ctx, logger, client); err != nil {
-		if mainErr, ok := err.(*mainFuncError); ok {
-			logger.Error(mainErr.msg, mainErr.Fields()...)
-		} else {
-			msg := fmt.Sprintf("%s.RunFunc", appName)
-			logger.Error(msg, zap.Error(err))
+		logRunFuncError(logger, appName, err)
+		hooks.onError(ctx, logger, client, err)
+	}
+
+	// A run cancelled by the liveness watchdog is never considered successful,
+	// no matter what the `RunFunc` returned.
+	var tripErr error
+	if liveness != nil {
+		tripErr = liveness.tripError()
+	}
+	switch {
+	case tripErr != nil:
+		res = res.withError(ExitLiveness, tripErr)
+	case err != nil:
+		res = res.withError(exitCodeOf(err), err)
+		if compErr := (*ComponentError)(nil); errors.As(err, &compErr) {
+			res.Component = compErr.Component
 		}
-		return exitInternal
 	}
 
-	return exitOK
+	// Call the hooks that run after the `RunFunc`. Their errors only fail an
+	// otherwise successful run.
+	if hookErr := hooks.afterRun(ctx, logger, client, hooksRun); hookErr != nil && res.ExitCode == ExitOK {
+		res = res.withError(exitCodeOf(hookErr), hookErr)
+	}
+
+	// In batch mode, report the summary of the job.
+	if cfg.batchMode {
+		reportBatchSummary(logger, client, cfg.batchSummaryDataset, appName, progress, res)
+	}
+
+	return res
 }
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+)
+
+// withTestAxiomOptions configures an Axiom client which doesn't take its
+// configuration from the environment and is never contacted.
+func withTestAxiomOptions() Option {
+	return WithAxiomOptions(
+		axiom.SetNoEnv(),
+		axiom.SetURL("http://axiom.local"),
+		axiom.SetAccessToken("xapt-1234"),
+	)
+}
+
+// withTestIngestServer configures an Axiom client which talks to a test server
+// accepting ingestion requests. The ingested events are sent to the returned
+// channel, keyed by dataset.
+func withTestIngestServer(t *testing.T) (Option, <-chan ingestedEvent) {
+	t.Helper()
+
+	eventCh := make(chan ingestedEvent, 64)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !assert.Regexp(t, "^/api/v1/datasets/[^/]+/ingest$", r.URL.Path) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		dataset := r.URL.Path[len("/api/v1/datasets/") : len(r.URL.Path)-len("/ingest")]
+
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gzr, err := gzip.NewReader(r.Body)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer gzr.Close()
+			body = gzr
+		}
+
+		var n int
+		for dec := json.NewDecoder(body); dec.More(); n++ {
+			var event axiom.Event
+			if !assert.NoError(t, dec.Decode(&event)) {
+				return
+			}
+			select {
+			case eventCh <- ingestedEvent{dataset, event}:
+			default:
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(axiom.IngestStatus{Ingested: uint64(n)})
+	}))
+	t.Cleanup(srv.Close)
+
+	return WithAxiomOptions(
+		axiom.SetNoEnv(),
+		axiom.SetURL(srv.URL),
+		axiom.SetAccessToken("xapt-1234"),
+	), eventCh
+}
+
+// ingestedEvent is an event received by the test ingest server.
+type ingestedEvent struct {
+	dataset string
+	event   axiom.Event
+}
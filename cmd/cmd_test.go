@@ -2,15 +2,35 @@ package cmd_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"syscall"
+	"testing"
+	"time"
 
 	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
 	"github.com/axiomhq/pkg/cmd"
+	xerrors "github.com/axiomhq/pkg/errors"
+	"github.com/axiomhq/pkg/flags"
+	"github.com/axiomhq/pkg/logctx"
+	"github.com/axiomhq/pkg/scheduler"
 )
 
+// withTestAxiomOptions configures an Axiom client which doesn't take its
+// configuration from the environment and is never contacted.
+func withTestAxiomOptions() cmd.Option {
+	return cmd.WithAxiomOptions(
+		axiom.SetNoEnv(),
+		axiom.SetURL("http://axiom.local"),
+		axiom.SetAccessToken("xapt-1234"),
+	)
+}
+
 func Example() {
 	os.Clearenv()
 	os.Setenv("DEBUG", "1")
@@ -34,3 +54,130 @@ func Example() {
 	// Output:
 	// Hello World!
 }
+
+func TestRunE(t *testing.T) {
+	axiomOptions := withTestAxiomOptions()
+
+	t.Run("ok", func(t *testing.T) {
+		res := cmd.RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error {
+			return nil
+		}, axiomOptions)
+
+		assert.Equal(t, cmd.ExitOK, res.ExitCode)
+		assert.NoError(t, res.Err)
+		assert.Nil(t, res.Signal)
+		assert.False(t, res.StartTime.IsZero())
+		assert.NotZero(t, res.StartupDuration)
+		assert.GreaterOrEqual(t, res.Duration, res.StartupDuration)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		testErr := errors.New("test error")
+
+		res := cmd.RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error {
+			return cmd.Error("run failed", testErr)
+		}, axiomOptions)
+
+		assert.Equal(t, cmd.ExitInternal, res.ExitCode)
+		assert.True(t, errors.Is(res.Err, testErr))
+	})
+
+	t.Run("categorized error", func(t *testing.T) {
+		res := cmd.RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error {
+			err := xerrors.WithCategory(errors.New("connection refused"), xerrors.Unavailable)
+			return fmt.Errorf("query: %w", err)
+		}, axiomOptions)
+
+		assert.Equal(t, cmd.ExitUnavailable, res.ExitCode)
+		assert.EqualError(t, res.Err, "query: connection refused")
+	})
+
+	t.Run("config error", func(t *testing.T) {
+		res := cmd.RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error {
+			require.FailNow(t, "must not be called")
+			return nil
+		}, axiomOptions, cmd.WithRequiredEnvVars("CMD_TEST_UNSET"))
+
+		assert.Equal(t, cmd.ExitConfig, res.ExitCode)
+		assert.EqualError(t, res.Err, `missing environment variable "CMD_TEST_UNSET"`)
+		assert.Zero(t, res.StartupDuration)
+	})
+
+	t.Run("signal", func(t *testing.T) {
+		res := cmd.RunE("test", func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+			p, err := os.FindProcess(os.Getpid())
+			require.NoError(t, err)
+			require.NoError(t, p.Signal(syscall.SIGHUP))
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second * 5):
+				require.FailNow(t, "context not cancelled by signal")
+			}
+			return nil
+		}, axiomOptions, cmd.WithExitSignals(syscall.SIGHUP))
+
+		assert.Equal(t, cmd.ExitOK, res.ExitCode)
+		assert.Equal(t, syscall.SIGHUP, res.Signal)
+	})
+}
+
+func TestRunE_Jobs(t *testing.T) {
+	axiomOptions := withTestAxiomOptions()
+	runCh := make(chan struct{}, 1)
+
+	res := cmd.RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error {
+		select {
+		case <-runCh:
+		case <-time.After(time.Second * 5):
+			require.FailNow(t, "job did not run")
+		}
+		return nil
+	},
+		axiomOptions,
+		cmd.WithJobs(scheduler.Job{
+			Name:     "test",
+			Schedule: scheduler.Every(time.Millisecond),
+			Func: func(context.Context) error {
+				select {
+				case runCh <- struct{}{}:
+				default:
+				}
+				return nil
+			},
+		}),
+	)
+	assert.Equal(t, cmd.ExitOK, res.ExitCode)
+
+	res = cmd.RunE("test", nil, axiomOptions, cmd.WithJobs(scheduler.Job{Name: "invalid"}))
+	assert.Equal(t, cmd.ExitConfig, res.ExitCode)
+}
+
+func TestRunE_Flags(t *testing.T) {
+	axiomOptions := withTestAxiomOptions()
+
+	set, err := flags.New()
+	require.NoError(t, err)
+	newIngest := set.Bool("new-ingest", false, "")
+
+	t.Setenv(set.EnvName("new-ingest"), "true")
+
+	res := cmd.RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error {
+		assert.True(t, newIngest.Enabled())
+		return nil
+	}, axiomOptions, cmd.WithFlags(set))
+	assert.Equal(t, cmd.ExitOK, res.ExitCode)
+
+	t.Setenv(set.EnvName("new-ingest"), "maybe")
+
+	res = cmd.RunE("test", nil, axiomOptions, cmd.WithFlags(set))
+	assert.Equal(t, cmd.ExitConfig, res.ExitCode)
+}
+
+func TestRunE_ContextLogger(t *testing.T) {
+	res := cmd.RunE("test", func(ctx context.Context, logger *zap.Logger, _ *axiom.Client) error {
+		assert.Equal(t, logger, logctx.From(ctx))
+		return nil
+	}, withTestAxiomOptions())
+	assert.Equal(t, cmd.ExitOK, res.ExitCode)
+}
@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+// commandSpec describes the command line interface and environment of an
+// application. It is the source of the generated shell completion scripts and
+// man pages.
+type commandSpec struct {
+	name        string
+	description string
+	flags       []flagSpec
+	subcommands []Subcommand
+	envVars     []envVarSpec
+	signals     []signalSpec
+	exitCodes   []exitCodeSpec
+}
+
+// Subcommand describes a subcommand of the application, see
+// `WithSubcommands()`.
+type Subcommand struct {
+	// Name of the subcommand. It is passed as the first positional argument.
+	Name string
+	// Usage is a short, one line description of the subcommand.
+	Usage string
+}
+
+// subcommandName matches valid subcommand names. They are used unquoted in the
+// generated shell completion scripts.
+var subcommandName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// flagSpec describes a command line flag.
+type flagSpec struct {
+	name  string
+	usage string
+	// value is the name of the value the flag takes. It is empty for boolean
+	// flags, which don't take one.
+	value string
+	def   string
+}
+
+// envVarSpec describes an environment variable the application reads.
+type envVarSpec struct {
+	name     string
+	usage    string
+	required bool
+}
+
+// signalSpec describes how the application reacts to a signal.
+type signalSpec struct {
+	name  string
+	usage string
+}
+
+// exitCodeSpec describes when the application exits with an exit code.
+type exitCodeSpec struct {
+	code  ExitCode
+	usage string
+}
+
+// newCommandSpec creates the spec of the application from its configuration.
+func newCommandSpec(appName string, c *config) commandSpec {
+	spec := commandSpec{
+		name:        appName,
+		description: c.description,
+		subcommands: c.subcommands,
+	}
+
+	if c.commandLine != nil {
+		c.commandLine.VisitAll(func(f *flag.Flag) {
+			value, usage := flag.UnquoteUsage(f)
+			spec.flags = append(spec.flags, flagSpec{name: f.Name, usage: usage, value: value, def: f.DefValue})
+		})
+	}
+
+	// Application scoped variables are documented by their prefixed name.
+	var envPrefix string
+	if c.appEnvPrefix {
+		envPrefix = appEnvPrefix(appName)
+	}
+	scoped := func(env envVarSpec) envVarSpec {
+		if envPrefix != "" {
+			env.usage = strings.TrimSpace(fmt.Sprintf("%s Falls back to %s.", env.usage, env.name))
+			env.name = envPrefix + env.name
+		}
+		return env
+	}
+
+	for _, env := range c.requiredEnvVars {
+		spec.envVars = append(spec.envVars, scoped(envVarSpec{name: env, required: true}))
+	}
+	spec.envVars = append(spec.envVars,
+		scoped(envVarSpec{name: "AXIOM_URL", usage: "URL of the Axiom deployment."}),
+		scoped(envVarSpec{name: "AXIOM_TOKEN", usage: "Access token for Axiom."}),
+		scoped(envVarSpec{name: "AXIOM_ORG_ID", usage: "Organization ID of the Axiom account."}),
+	)
+	for _, name := range c.axiomProfiles {
+		prefix := profileEnvPrefix(name)
+		spec.envVars = append(spec.envVars,
+			scoped(envVarSpec{name: prefix + "URL", usage: fmt.Sprintf("URL of the Axiom deployment of the %q profile.", name)}),
+			scoped(envVarSpec{name: prefix + "TOKEN", usage: fmt.Sprintf("Access token for the %q profile.", name)}),
+			scoped(envVarSpec{name: prefix + "ORG_ID", usage: fmt.Sprintf("Organization ID of the %q profile.", name)}),
+		)
+	}
+	if c.flags != nil {
+		for _, v := range c.flags.Values() {
+			usage := fmt.Sprintf("Value of the %s flag %q. Defaults to %q.", v.Type, v.Name, v.Default)
+			if v.Usage != "" {
+				usage = v.Usage + " " + usage
+			}
+			spec.envVars = append(spec.envVars, scoped(envVarSpec{name: c.flags.EnvName(v.Name), usage: usage}))
+		}
+	}
+	spec.envVars = append(spec.envVars,
+		scoped(envVarSpec{name: "DEBUG", usage: "Enables development logging if set to true."}),
+		envVarSpec{name: "NOTIFY_SOCKET", usage: "Socket of the service manager to send notifications to."},
+		envVarSpec{name: "WATCHDOG_USEC", usage: "Watchdog interval of the service manager in microseconds."},
+	)
+
+	seen := make(map[string]struct{})
+	addSignal := func(sig os.Signal, usage string) {
+		name := signalName(sig)
+		if _, ok := seen[name]; ok {
+			return
+		}
+		seen[name] = struct{}{}
+		spec.signals = append(spec.signals, signalSpec{name: name, usage: usage})
+	}
+	for _, sig := range c.exitSignals {
+		// SIGKILL can't be caught, so it is not worth mentioning.
+		switch sig {
+		case syscall.SIGKILL:
+		case goroutineDumpSignal:
+			addSignal(sig, "Dumps the stack traces of all goroutines to the log and stops the application gracefully.")
+		default:
+			addSignal(sig, "Stops the application gracefully.")
+		}
+	}
+	for _, sh := range c.signalHandlers {
+		addSignal(sh.sig, "Handled by the application.")
+	}
+	if sig := goroutineDumpSignal; sig != nil {
+		addSignal(sig, "Dumps the stack traces of all goroutines to the log.")
+	}
+	if sig := profileDumpSignal; c.profileDumps && sig != nil {
+		addSignal(sig, "Writes heap, goroutine, mutex, block and CPU profiles to disk.")
+	}
+
+	// Exit codes that depend on a feature are only documented if it is
+	// enabled.
+	spec.exitCodes = append(spec.exitCodes,
+		exitCodeSpec{code: ExitOK, usage: "The application exited gracefully."},
+		exitCodeSpec{code: ExitInternal, usage: "The application failed with an uncategorized error."},
+		exitCodeSpec{code: ExitConfig, usage: "The application is misconfigured or failed to start up."},
+	)
+	if c.pidFile != "" || c.instanceLock != "" {
+		spec.exitCodes = append(spec.exitCodes, exitCodeSpec{code: ExitLocked, usage: "Another instance of the application is running."})
+	}
+	if c.livenessDeadline > 0 && c.livenessPolicy == LivenessExit {
+		spec.exitCodes = append(spec.exitCodes, exitCodeSpec{code: ExitLiveness, usage: "The liveness watchdog stopped the application."})
+	}
+	spec.exitCodes = append(spec.exitCodes,
+		exitCodeSpec{code: ExitRetryable, usage: "The application failed with a retryable error."},
+		exitCodeSpec{code: ExitPermanent, usage: "The application failed with a permanent error."},
+		exitCodeSpec{code: ExitInvalidInput, usage: "The application failed because of invalid input."},
+		exitCodeSpec{code: ExitUnavailable, usage: "The application failed because a dependency is unavailable."},
+	)
+
+	return spec
+}
+
+// signalName returns the conventional name of the signal, e.g. "SIGTERM".
+func signalName(sig os.Signal) string {
+	switch sig {
+	case syscall.SIGHUP:
+		return "SIGHUP"
+	case syscall.SIGINT:
+		return "SIGINT"
+	case syscall.SIGQUIT:
+		return "SIGQUIT"
+	case syscall.SIGKILL:
+		return "SIGKILL"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	}
+	if name, ok := platformSignalNames[sig]; ok {
+		return name
+	}
+	return sig.String()
+}
+
+// hiddenCommands are commands which are not listed anywhere but can be run by
+// passing their name as the first argument to the application, if enabled by
+// `WithHiddenCommands()`.
+var hiddenCommands = map[string]func(w io.Writer, spec commandSpec, args []string) error{
+	"completion": runCompletionCommand,
+	"man":        runManCommand,
+}
+
+// errUsage is returned by hidden commands that are invoked incorrectly.
+var errUsage = errors.New("usage")
+
+// runHiddenCommand runs the hidden command named by the first argument, if
+// any. It reports whether a hidden command was run.
+func runHiddenCommand(w io.Writer, spec commandSpec, args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	command, ok := hiddenCommands[args[0]]
+	if !ok {
+		return false, nil
+	}
+	return true, command(w, spec, args[1:])
+}
+
+// runCompletionCommand writes the completion script for the shell given as
+// argument.
+func runCompletionCommand(w io.Writer, spec commandSpec, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: %s completion bash|zsh|fish", errUsage, spec.name)
+	}
+	generate, ok := completionGenerators[args[0]]
+	if !ok {
+		return fmt.Errorf("%w: %s completion bash|zsh|fish: unsupported shell %q", errUsage, spec.name, args[0])
+	}
+	return generate(w, spec)
+}
+
+// runManCommand writes the man page.
+func runManCommand(w io.Writer, spec commandSpec, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("%w: %s man", errUsage, spec.name)
+	}
+	return writeManPage(w, spec)
+}
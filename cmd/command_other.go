@@ -0,0 +1,9 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package cmd
+
+import "os"
+
+// platformSignalNames are the conventional names of signals only available on
+// this platform.
+var platformSignalNames = map[os.Signal]string{}
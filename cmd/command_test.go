@@ -0,0 +1,189 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/axiomhq/pkg/flags"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// testCommandSpec returns the spec of an application using most options.
+func testCommandSpec(t *testing.T) commandSpec {
+	t.Helper()
+
+	commandLine := flag.NewFlagSet("ingestd", flag.ContinueOnError)
+	commandLine.String("addr", ":8080", "The `address` to serve the ingest API on.")
+	commandLine.Bool("dry-run", false, "Validates events [without] ingesting them.")
+
+	set, err := flags.New()
+	require.NoError(t, err)
+	set.Bool("new-ingest", false, "Use the new ingest path.")
+
+	cfg := &config{exitSignals: DefaultExitSignals()}
+	for _, option := range []Option{
+		WithDescription("Ingests events into Axiom."),
+		WithRequiredEnvVars("INGEST_DATASET"),
+		WithAxiomProfiles("source"),
+		WithFlags(set),
+		WithCommandLine(commandLine),
+		WithSubcommands(
+			Subcommand{Name: "serve", Usage: "Serves the ingest API."},
+			Subcommand{Name: "migrate", Usage: "Migrates the dataset's schema: adds missing fields."},
+		),
+		WithSignalHandler(syscall.SIGUSR1, func(context.Context) {}),
+		WithProfileDumps(""),
+	} {
+		require.NoError(t, option(cfg))
+	}
+
+	return newCommandSpec("ingestd", cfg)
+}
+
+func TestHiddenCommands(t *testing.T) {
+	spec := testCommandSpec(t)
+
+	for _, tt := range []struct {
+		args   []string
+		golden string
+	}{
+		{[]string{"completion", "bash"}, "ingestd.bash"},
+		{[]string{"completion", "zsh"}, "ingestd.zsh"},
+		{[]string{"completion", "fish"}, "ingestd.fish"},
+		{[]string{"man"}, "ingestd.1"},
+	} {
+		t.Run(tt.golden, func(t *testing.T) {
+			var buf bytes.Buffer
+			ok, err := runHiddenCommand(&buf, spec, tt.args)
+			require.True(t, ok)
+			require.NoError(t, err)
+
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644)) //nolint:gosec // Golden files are not sensitive.
+			}
+
+			want, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), buf.String())
+		})
+	}
+}
+
+func TestHiddenCommands_Usage(t *testing.T) {
+	spec := testCommandSpec(t)
+
+	for _, args := range [][]string{
+		{"completion"},
+		{"completion", "powershell"},
+		{"man", "extra"},
+	} {
+		ok, err := runHiddenCommand(&bytes.Buffer{}, spec, args)
+		assert.True(t, ok)
+		assert.True(t, errors.Is(err, errUsage), args)
+	}
+
+	ok, err := runHiddenCommand(&bytes.Buffer{}, spec, []string{"serve"})
+	assert.False(t, ok)
+	assert.NoError(t, err)
+}
+
+func TestNewCommandSpec_ExitCodes(t *testing.T) {
+	codes := func(options ...Option) (codes []ExitCode) {
+		cfg := &config{}
+		for _, option := range options {
+			require.NoError(t, option(cfg))
+		}
+		for _, ec := range newCommandSpec("test", cfg).exitCodes {
+			codes = append(codes, ec.code)
+		}
+		return codes
+	}
+
+	assert.NotContains(t, codes(), ExitLocked)
+	assert.NotContains(t, codes(), ExitLiveness)
+	assert.Contains(t, codes(WithInstanceLock("test.lock")), ExitLocked)
+	assert.Contains(t, codes(WithPIDFile("test.pid")), ExitLocked)
+	assert.NotContains(t, codes(WithLivenessWatchdog(time.Second, LivenessFailHealth)), ExitLiveness)
+	assert.Contains(t, codes(WithLivenessWatchdog(time.Second, LivenessExit)), ExitLiveness)
+}
+
+func TestRun_HiddenCommand(t *testing.T) {
+	var buf bytes.Buffer
+	res := RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error {
+		require.FailNow(t, "must not be called")
+		return nil
+	}, withTestAxiomOptions(), WithHiddenCommands(), WithArgs("completion", "fish"), WithIO(nil, &buf, nil))
+	assert.Equal(t, ExitOK, res.ExitCode)
+	assert.Contains(t, buf.String(), "complete -c test")
+}
+
+func TestRun_HiddenCommand_Disabled(t *testing.T) {
+	var (
+		buf  bytes.Buffer
+		args []string
+	)
+	res := RunE("test", func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		args = InvocationFrom(ctx).Args
+		return nil
+	}, withTestAxiomOptions(), WithArgs("man"), WithIO(nil, &buf, nil))
+	assert.Equal(t, ExitOK, res.ExitCode)
+	assert.Equal(t, []string{"man"}, args)
+	assert.Empty(t, buf.String())
+}
+
+func TestRun_CommandLine(t *testing.T) {
+	newCommandLine := func() (*flag.FlagSet, *bool) {
+		set := flag.NewFlagSet("test", flag.ContinueOnError)
+		set.SetOutput(io.Discard)
+		return set, set.Bool("dry-run", false, "")
+	}
+
+	set, dryRun := newCommandLine()
+	var args []string
+	res := RunE("test", func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		args = InvocationFrom(ctx).Args
+		return nil
+	}, withTestAxiomOptions(), WithCommandLine(set), WithArgs("-dry-run", "serve", "-v"))
+	assert.Equal(t, ExitOK, res.ExitCode)
+	assert.True(t, *dryRun)
+	assert.Equal(t, []string{"serve", "-v"}, args)
+
+	fn := func(context.Context, *zap.Logger, *axiom.Client) error {
+		require.FailNow(t, "must not be called")
+		return nil
+	}
+
+	set, _ = newCommandLine()
+	res = RunE("test", fn, withTestAxiomOptions(), WithCommandLine(set), WithArgs("-unknown"))
+	assert.Equal(t, ExitConfig, res.ExitCode)
+
+	set, _ = newCommandLine()
+	res = RunE("test", fn, withTestAxiomOptions(), WithCommandLine(set), WithArgs("-h"))
+	assert.Equal(t, ExitOK, res.ExitCode)
+	assert.NoError(t, res.Err)
+}
+
+func TestWithSubcommands(t *testing.T) {
+	cfg := new(config)
+	assert.NoError(t, WithSubcommands(Subcommand{Name: "serve"})(cfg))
+	assert.EqualError(t, WithSubcommands(Subcommand{Name: "serve"})(cfg), `subcommand "serve" declared twice`)
+	assert.EqualError(t, WithSubcommands(Subcommand{Name: "-v"})(cfg), `invalid subcommand name "-v"`)
+	assert.EqualError(t, WithSubcommands(Subcommand{})(cfg), `invalid subcommand name ""`)
+}
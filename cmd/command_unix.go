@@ -0,0 +1,16 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// platformSignalNames are the conventional names of signals only available on
+// this platform.
+var platformSignalNames = map[os.Signal]string{
+	syscall.SIGUSR1:  "SIGUSR1",
+	syscall.SIGUSR2:  "SIGUSR2",
+	syscall.SIGWINCH: "SIGWINCH",
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// completionShells are the shells completion scripts can be generated for.
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionGenerators generate the completion script for a shell.
+var completionGenerators = map[string]func(io.Writer, commandSpec) error{
+	"bash": completionTemplate(bashCompletion),
+	"zsh":  completionTemplate(zshCompletion),
+	"fish": completionTemplate(fishCompletion),
+}
+
+// The completion scripts complete the declared command line flags and
+// subcommands, falling back to files for flag values and other arguments. They
+// also complete the arguments of the hidden completion command, so the scripts
+// of other shells can be generated with the help of completion. Hidden commands
+// themselves are not completed. Environment variables can't be completed as
+// part of the command line and are documented in the man page instead.
+const (
+	bashCompletion = `# bash completion for {{ .Name }}
+#
+# Load it by running: source <({{ .Name }} completion bash)
+
+_{{ .Func }}() {
+	local cur="${COMP_WORDS[COMP_CWORD]}"
+	if [[ ${COMP_CWORD} -eq 2 && ${COMP_WORDS[1]} == completion ]]; then
+		COMPREPLY=($(compgen -W "{{ .Shells }}" -- "${cur}"))
+		return
+	fi
+{{- with .ValueFlags }}
+	case "${COMP_WORDS[COMP_CWORD-1]}" in
+	{{ . }}) return ;;
+	esac
+{{- end }}
+{{- with .Flags }}
+	if [[ ${cur} == -* ]]; then
+		COMPREPLY=($(compgen -W "{{ range $i, $f := . }}{{ if $i }} {{ end }}-{{ $f.Name }}{{ end }}" -- "${cur}"))
+		return
+	fi
+{{- end }}
+{{- with .Subcommands }}
+	local i
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case "${COMP_WORDS[i]}" in
+{{- with $.ValueFlags }}
+		{{ . }}) [[ ${COMP_WORDS[i+1]} == = ]] && ((i++)); ((i++)) ;;
+{{- end }}
+		-* | =) ;;
+		*) return ;;
+		esac
+	done
+	COMPREPLY=($(compgen -W "{{ range $i, $sc := . }}{{ if $i }} {{ end }}{{ $sc.Name }}{{ end }}" -- "${cur}"))
+{{- end }}
+}
+
+complete -o default -F _{{ .Func }} {{ .Name }}
+`
+
+	zshCompletion = `#compdef {{ .Name }}
+#
+# zsh completion for {{ .Name }}
+#
+# Load it by running: source <({{ .Name }} completion zsh)
+
+_{{ .Func }}() {
+	if (( CURRENT == 3 )) && [[ ${words[2]} == completion ]]; then
+		_values 'shell' {{ .Shells }}
+		return
+	fi
+{{- with .Subcommands }}
+	local -a subcommands=(
+{{- range . }}
+		'{{ zshDescribe .Name .Usage }}'
+{{- end }}
+	)
+{{- end }}
+	_arguments \
+{{- range .Flags }}
+{{- if .Value }}
+		'-{{ .Name }}=[{{ zshOptionUsage .Usage }}]:{{ zshOptionUsage .Value }}:_files' \
+{{- else }}
+		'-{{ .Name }}[{{ zshOptionUsage .Usage }}]' \
+{{- end }}
+{{- end }}
+{{- if .Subcommands }}
+		'1:subcommand:_describe subcommand subcommands' \
+{{- end }}
+		'*:argument:_files'
+}
+
+compdef _{{ .Func }} {{ .Name }}
+`
+
+	fishCompletion = `# fish completion for {{ .Name }}
+#
+# Load it by running: {{ .Name }} completion fish | source
+
+complete -c {{ .Name }} -n '__fish_seen_subcommand_from completion' -f -a '{{ .Shells }}'
+{{- range .Flags }}
+complete -c {{ $.Name }} -o {{ .Name }}{{ if .Value }} -r{{ end }} -d '{{ fishQuote .Usage }}'
+{{- end }}
+{{- range .Subcommands }}
+complete -c {{ $.Name }} -n '__fish_use_subcommand' -f -a {{ .Name }} -d '{{ fishQuote .Usage }}'
+{{- end }}
+`
+)
+
+// nonIdentChars are characters that are not allowed in shell function names.
+var nonIdentChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// completionFuncs quote text for use in the completion script templates.
+var completionFuncs = template.FuncMap{
+	"fishQuote":      fishQuoter.Replace,
+	"zshOptionUsage": zshOptionQuoter.Replace,
+	"zshDescribe": func(name, usage string) string {
+		return zshQuoter.Replace(strings.ReplaceAll(name, ":", `\:`) + ":" + usage)
+	},
+}
+
+var (
+	// fishQuoter escapes text for use in single quoted fish strings.
+	fishQuoter = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", " ")
+	// zshQuoter escapes text for use in single quoted zsh strings.
+	zshQuoter = strings.NewReplacer(`'`, `'\''`, "\n", " ")
+	// zshOptionQuoter escapes text for use in the option specs of `_arguments`,
+	// which are single quoted zsh strings.
+	zshOptionQuoter = strings.NewReplacer(`'`, `'\''`, `\`, `\\`, `[`, `\[`, `]`, `\]`, `:`, `\:`, "\n", " ")
+)
+
+// completionFlag is a command line flag as passed to the completion script
+// templates.
+type completionFlag struct {
+	Name  string
+	Usage string
+	Value string
+}
+
+// completionTemplate returns a generator which renders the given completion
+// script template.
+func completionTemplate(text string) func(io.Writer, commandSpec) error {
+	tmpl := template.Must(template.New("completion").Funcs(completionFuncs).Parse(text))
+	return func(w io.Writer, spec commandSpec) error {
+		// Flags taking a value are completed with files by default.
+		var (
+			flags      []completionFlag
+			valueFlags []string
+		)
+		for _, f := range spec.flags {
+			flags = append(flags, completionFlag{Name: f.name, Usage: f.usage, Value: f.value})
+			if f.value != "" {
+				valueFlags = append(valueFlags, "-"+f.name)
+			}
+		}
+
+		return tmpl.Execute(w, struct {
+			Name        string
+			Func        string
+			Shells      string
+			Flags       []completionFlag
+			ValueFlags  string
+			Subcommands []Subcommand
+		}{
+			Name:        spec.name,
+			Func:        nonIdentChars.ReplaceAllString(spec.name, "_"),
+			Shells:      strings.Join(completionShells, " "),
+			Flags:       flags,
+			ValueFlags:  strings.Join(valueFlags, " | "),
+			Subcommands: spec.subcommands,
+		})
+	}
+}
@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"flag"
 	"os"
+	"time"
 
 	"github.com/axiomhq/axiom-go/axiom"
 	"go.uber.org/zap"
+
+	"github.com/axiomhq/pkg/flags"
+	"github.com/axiomhq/pkg/scheduler"
 )
 
 type config struct {
@@ -13,4 +19,40 @@ type config struct {
 	requiredEnvVars          []string
 	exitSignals              []os.Signal
 	validateAxiomCredentials bool
+	pidFile                  string
+	instanceLock             string
+	manualReadiness          bool
+	healthCheck              func(context.Context) error
+	livenessPolicy           LivenessPolicy
+	livenessDeadline         time.Duration
+	heartbeatDeadlines       map[string]time.Duration
+	containerTuning          bool
+	memoryHeadroom           float64
+	cgroupRoot               string
+	runtimeStatsInterval     time.Duration
+	runtimeStatsDataset      string
+	jobs                     []scheduler.Job
+	batchMode                bool
+	batchProgressInterval    time.Duration
+	batchSummaryDataset      string
+	axiomProfiles            []string
+	axiomConfigFile          string
+	lifecycleDataset         string
+	lifecycleTimeout         time.Duration
+	drainDelay               time.Duration
+	flags                    *flags.Set
+	supervisor               *SupervisorPolicy
+	hooks                    []Hooks
+	profileDumps             bool
+	profileDumpDir           string
+	signalHandlers           []signalHandler
+	description              string
+	hiddenCommands           bool
+	commandLine              *flag.FlagSet
+	subcommands              []Subcommand
+	invocation               Invocation
+	appEnvPrefix             bool
+	slowPhaseThreshold       time.Duration
+	rlimits                  []Rlimit
+	hardening                *HardeningPolicy
 }
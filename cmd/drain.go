@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+// The states of the application readiness.
+const (
+	readinessPending int32 = iota
+	readinessReady
+	readinessDraining
+)
+
+// readiness tracks whether the application is ready to serve. It is safe for
+// concurrent use.
+type readiness struct {
+	state int32
+
+	// healthCheck fails while the application is unhealthy and thus not ready
+	// to serve. It must be set before the readiness is used concurrently.
+	healthCheck func(context.Context) error
+}
+
+// setReady marks the application ready, unless it is already draining.
+func (r *readiness) setReady() {
+	atomic.CompareAndSwapInt32(&r.state, readinessPending, readinessReady)
+}
+
+// drain marks the application as draining. It is never ready again.
+func (r *readiness) drain() {
+	atomic.StoreInt32(&r.state, readinessDraining)
+}
+
+// ready reports whether the application is ready to serve.
+func (r *readiness) ready() bool {
+	if atomic.LoadInt32(&r.state) != readinessReady {
+		return false
+	}
+	return r.healthCheck == nil || r.healthCheck(context.Background()) == nil
+}
+
+type readinessKey struct{}
+
+// readinessFromContext returns the readiness carried by the context. A
+// readiness that is never marked ready is returned if the context doesn't carry
+// one.
+func readinessFromContext(ctx context.Context) *readiness {
+	if r, ok := ctx.Value(readinessKey{}).(*readiness); ok {
+		return r
+	}
+	return new(readiness)
+}
+
+// IsReady reports whether the application is ready to serve. It is ready once
+// startup finished, or `Ready()` was called when the `WithManualReadiness()`
+// option is used, and is no longer ready as soon as an exit signal arrives.
+// While a loop monitored by the liveness watchdog misses its deadline, the
+// application is not ready either. The context must be the one passed to the
+// `RunFunc`.
+func IsReady(ctx context.Context) bool {
+	return readinessFromContext(ctx).ready()
+}
+
+// ReadinessHandler returns a handler which responds with "200 OK" if the
+// application is ready to serve and "503 Service Unavailable" otherwise. It is
+// meant to be polled by load balancers and orchestrators. The context must be
+// the one passed to the `RunFunc`.
+func ReadinessHandler(ctx context.Context) http.Handler {
+	r := readinessFromContext(ctx)
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if !r.ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("not ready\n"))
+			return
+		}
+		_, _ = w.Write([]byte("ready\n"))
+	})
+}
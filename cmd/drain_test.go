@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRun_DrainDelay(t *testing.T) {
+	const drainDelay = time.Millisecond * 200
+
+	res := RunE("test", func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		require.True(t, IsReady(ctx))
+
+		p, err := os.FindProcess(os.Getpid())
+		require.NoError(t, err)
+		require.NoError(t, p.Signal(syscall.SIGHUP))
+		signalled := time.Now()
+
+		// The application stops being ready before the context is marked done.
+		require.Eventually(t, func() bool { return !IsReady(ctx) }, time.Second, time.Millisecond)
+		assert.NoError(t, ctx.Err())
+
+		rec := httptest.NewRecorder()
+		ReadinessHandler(ctx).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second * 5):
+			require.FailNow(t, "context not cancelled after drain delay")
+		}
+		assert.GreaterOrEqual(t, time.Since(signalled), drainDelay)
+
+		return nil
+	}, withTestAxiomOptions(), WithExitSignals(syscall.SIGHUP), WithDrainDelay(drainDelay))
+
+	assert.Equal(t, ExitOK, res.ExitCode)
+	assert.Equal(t, syscall.SIGHUP, res.Signal)
+}
+
+func TestReadinessHandler(t *testing.T) {
+	r := new(readiness)
+	ctx := context.WithValue(context.Background(), readinessKey{}, r)
+
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		ReadinessHandler(ctx).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve())
+	r.setReady()
+	assert.Equal(t, http.StatusOK, serve())
+	r.drain()
+	assert.Equal(t, http.StatusServiceUnavailable, serve())
+
+	// Once draining, the application never becomes ready again.
+	r.setReady()
+	assert.False(t, IsReady(ctx))
+}
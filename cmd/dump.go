@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// cpuProfileDuration is the duration of the CPU profile of a profile dump.
+	cpuProfileDuration = time.Second * 30
+	// mutexProfileFraction is the fraction of mutex contention events
+	// recorded when profile dumps are enabled.
+	mutexProfileFraction = 100
+	// blockProfileRate is the rate, in nanoseconds spent blocked, of blocking
+	// events recorded when profile dumps are enabled.
+	blockProfileRate = int(time.Millisecond)
+)
+
+// dumpProfiles are the profiles written by a profile dump, in addition to the
+// CPU profile.
+var dumpProfiles = []string{"heap", "goroutine", "mutex", "block"}
+
+// profileDumper writes runtime profiles into timestamped directories. Only
+// one dump runs at a time.
+type profileDumper struct {
+	logger      *zap.Logger
+	dir         string
+	appName     string
+	cpuDuration time.Duration
+
+	running int32
+
+	mu      sync.Mutex
+	stopped bool
+	dumps   sync.WaitGroup
+}
+
+// newProfileDumper creates a new profile dumper which writes into the given
+// directory. It enables mutex and block profiling, so their profiles contain
+// data.
+func newProfileDumper(logger *zap.Logger, dir, appName string) *profileDumper {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	runtime.SetMutexProfileFraction(mutexProfileFraction)
+	runtime.SetBlockProfileRate(blockProfileRate)
+
+	return &profileDumper{
+		logger:      logger,
+		dir:         dir,
+		appName:     appName,
+		cpuDuration: cpuProfileDuration,
+	}
+}
+
+// start a dump in the background. Use `wait()` to wait for it to finish.
+func (d *profileDumper) start(ctx context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return
+	}
+
+	d.dumps.Add(1)
+	go func() {
+		defer d.dumps.Done()
+		d.dump(ctx)
+	}()
+}
+
+// wait for all dumps started in the background to finish. No dumps are
+// started afterwards.
+func (d *profileDumper) wait() {
+	d.mu.Lock()
+	d.stopped = true
+	d.mu.Unlock()
+
+	d.dumps.Wait()
+}
+
+// dump writes all profiles into a new directory. The CPU profile is cut short
+// if the context is marked done. A dump is skipped if another one is still
+// running.
+func (d *profileDumper) dump(ctx context.Context) {
+	if !atomic.CompareAndSwapInt32(&d.running, 0, 1) {
+		d.logger.Warn("profile dump skipped, previous dump still running")
+		return
+	}
+	defer atomic.StoreInt32(&d.running, 0)
+
+	dir := filepath.Join(d.dir, fmt.Sprintf("%s-%s", d.appName, time.Now().UTC().Format("20060102T150405Z")))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		d.logger.Error("create profile dump directory", zap.Error(err), zap.String("path", dir))
+		return
+	}
+	d.logger.Info("writing profile dump", zap.String("path", dir), zap.Duration("cpu_duration", d.cpuDuration))
+
+	for _, name := range dumpProfiles {
+		profile := pprof.Lookup(name)
+		err := writeProfile(filepath.Join(dir, name+".pprof"), func(f *os.File) error {
+			return profile.WriteTo(f, 0)
+		})
+		if err != nil {
+			d.logger.Error("write profile", zap.Error(err), zap.String("profile", name))
+		}
+	}
+
+	err := writeProfile(filepath.Join(dir, "cpu.pprof"), func(f *os.File) error {
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return err
+		}
+		defer pprof.StopCPUProfile()
+
+		t := time.NewTimer(d.cpuDuration)
+		defer t.Stop()
+
+		select {
+		case <-ctx.Done():
+		case <-t.C:
+		}
+		return nil
+	})
+	if err != nil {
+		d.logger.Error("write profile", zap.Error(err), zap.String("profile", "cpu"))
+	}
+
+	d.logger.Info("profile dump written", zap.String("path", dir))
+}
+
+// writeProfile creates the file and writes a profile into it.
+func writeProfile(path string, write func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
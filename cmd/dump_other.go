@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package cmd
+
+import "os"
+
+// Debug signals are not supported on this platform.
+var (
+	goroutineDumpSignal os.Signal
+	profileDumpSignal   os.Signal
+)
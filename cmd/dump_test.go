@@ -0,0 +1,103 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRun_GoroutineDump(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	res := RunE("test", func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		p, err := os.FindProcess(os.Getpid())
+		require.NoError(t, err)
+		require.NoError(t, p.Signal(syscall.SIGQUIT))
+
+		<-ctx.Done()
+		return nil
+	}, withTestAxiomOptions(), WithLoggerOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(c, core)
+	})))
+	assert.Equal(t, ExitOK, res.ExitCode)
+	assert.Equal(t, syscall.SIGQUIT, res.Signal)
+
+	if dumps := logs.FilterMessage("goroutine dump").All(); assert.Len(t, dumps, 1) {
+		assert.Contains(t, dumps[0].ContextMap()["goroutines"], "cmd.TestRun_GoroutineDump")
+	}
+}
+
+func TestRun_GoroutineDump_KeepRunning(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	res := RunE("test", func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		p, err := os.FindProcess(os.Getpid())
+		require.NoError(t, err)
+		require.NoError(t, p.Signal(syscall.SIGQUIT))
+
+		require.Eventually(t, func() bool {
+			return logs.FilterMessage("goroutine dump").Len() == 1
+		}, time.Second*5, time.Millisecond*10)
+
+		// SIGQUIT is not an exit signal.
+		assert.NoError(t, ctx.Err())
+		return nil
+	}, withTestAxiomOptions(), WithExitSignals(os.Interrupt, syscall.SIGTERM), WithLoggerOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(c, core)
+	})))
+	assert.Equal(t, ExitOK, res.ExitCode)
+
+	dump := logs.FilterMessage("goroutine dump").All()[0].ContextMap()["goroutines"]
+	assert.Contains(t, dump, "cmd.TestRun_GoroutineDump_KeepRunning")
+}
+
+func TestRun_ProfileDump(t *testing.T) {
+	dir := t.TempDir()
+
+	res := RunE("test", func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		p, err := os.FindProcess(os.Getpid())
+		require.NoError(t, err)
+		require.NoError(t, p.Signal(syscall.SIGUSR2))
+
+		// The CPU profile is cut short when the application stops.
+		require.Eventually(t, func() bool {
+			matches, _ := filepath.Glob(filepath.Join(dir, "test-*", "block.pprof"))
+			return len(matches) == 1
+		}, time.Second*5, time.Millisecond*10)
+		return nil
+	}, withTestAxiomOptions(), WithProfileDumps(dir))
+	assert.Equal(t, ExitOK, res.ExitCode)
+
+	dumps, err := filepath.Glob(filepath.Join(dir, "test-*"))
+	require.NoError(t, err)
+	require.Len(t, dumps, 1)
+
+	for _, name := range append(dumpProfiles, "cpu") {
+		stat, err := os.Stat(filepath.Join(dumps[0], name+".pprof"))
+		if assert.NoError(t, err, name) {
+			assert.NotZero(t, stat.Size(), name)
+		}
+	}
+}
+
+func TestProfileDumper_Skip(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	d := newProfileDumper(zap.New(core), t.TempDir(), "test")
+	d.running = 1
+	d.dump(context.Background())
+
+	assert.Equal(t, 1, logs.FilterMessage("profile dump skipped, previous dump still running").Len())
+}
@@ -0,0 +1,15 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	// goroutineDumpSignal is the signal that dumps the goroutines to the log.
+	goroutineDumpSignal os.Signal = syscall.SIGQUIT
+	// profileDumpSignal is the signal that writes a profile dump.
+	profileDumpSignal os.Signal = syscall.SIGUSR2
+)
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// axiomEnvVars are the environment variables configuring the Axiom client.
+var axiomEnvVars = []string{"AXIOM_URL", "AXIOM_TOKEN", "AXIOM_ORG_ID"}
+
+// secretEnvVar matches the names of environment variables holding secrets.
+var secretEnvVar = regexp.MustCompile(`TOKEN|SECRET|PASSWORD|KEY|CREDENTIAL`)
+
+// maxSecretPrefixLen is the maximum length of the type prefix of a secret,
+// e.g. "xapt-", which is kept when masking it.
+const maxSecretPrefixLen = 5
+
+// maskSecret masks the secret, only keeping its type prefix, e.g. "xapt-****"
+// for an Axiom personal token.
+func maskSecret(secret string) string {
+	if i := strings.IndexByte(secret, '-'); i > 0 && i < maxSecretPrefixLen && i < len(secret)-1 {
+		return secret[:i+1] + "****"
+	}
+	return "****"
+}
+
+// nonEnvChars are characters that are not allowed in environment variable
+// names.
+var nonEnvChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// appEnvPrefix derives the prefix of application scoped environment variables
+// from the application name, e.g. "MY_APP_" for "my-app".
+func appEnvPrefix(appName string) string {
+	return nonEnvChars.ReplaceAllString(strings.ToUpper(appName), "_") + "_"
+}
+
+// envResolver looks up environment variables. If it has a prefix, the
+// prefixed name is looked up first, falling back to the unprefixed one.
+type envResolver struct {
+	prefix string
+	// lookupEnv looks up the variables. If nil, they are looked up in the
+	// environment of the process.
+	lookupEnv func(string) (string, bool)
+}
+
+// lookup the environment variable. It returns its value and the name of the
+// variable it was found in.
+func (r envResolver) lookup(name string) (value, source string, ok bool) {
+	lookupEnv := r.lookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+
+	if r.prefix != "" {
+		if value, ok = lookupEnv(r.prefix + name); ok {
+			return value, r.prefix + name, true
+		}
+	}
+	value, ok = lookupEnv(name)
+	return value, name, ok
+}
+
+// getenv returns the value of the environment variable, which is empty if it
+// is not set.
+func (r envResolver) getenv(name string) string {
+	value, _, _ := r.lookup(name)
+	return value
+}
+
+// axiomOptions returns the options configuring the Axiom client from the
+// environment variables. Unprefixed variables of the process environment are
+// picked up by the client itself, unless a lookup function replaces the
+// environment of the process.
+func (r envResolver) axiomOptions() []axiom.Option {
+	if r.prefix == "" && r.lookupEnv == nil {
+		return nil
+	}
+
+	var options []axiom.Option
+	if r.lookupEnv != nil {
+		options = append(options, axiom.SetNoEnv())
+	}
+	for _, name := range axiomEnvVars {
+		value, source, ok := r.lookup(name)
+		if !ok || (source == name && r.lookupEnv == nil) {
+			continue
+		}
+		switch name {
+		case "AXIOM_URL":
+			options = append(options, axiom.SetURL(value))
+		case "AXIOM_TOKEN":
+			options = append(options, axiom.SetAccessToken(value))
+		case "AXIOM_ORG_ID":
+			options = append(options, axiom.SetOrgID(value))
+		}
+	}
+	return options
+}
+
+// field returns a logger field describing the values of the given environment
+// variables and which variable each of them was found in. Values of secrets
+// are masked. Unset variables are omitted.
+func (r envResolver) field(names ...string) zap.Field {
+	return zap.Object("env", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		for _, name := range names {
+			value, source, ok := r.lookup(name)
+			if !ok {
+				continue
+			}
+			if secretEnvVar.MatchString(name) && value != "" {
+				value = maskSecret(value)
+			}
+			if err := enc.AddObject(name, zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+				enc.AddString("value", value)
+				enc.AddString("source", source)
+				return nil
+			})); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+// Getenv returns the value of the environment variable. If the application
+// uses application scoped environment variables, configured by the
+// `WithAppEnvPrefix()` option, the prefixed variable takes precedence over the
+// unprefixed one. The variables are looked up using the function configured by
+// the `WithEnvLookup()` option. The context must be the one passed to the
+// `RunFunc`.
+func Getenv(ctx context.Context, name string) string {
+	return InvocationFrom(ctx).Getenv(name)
+}
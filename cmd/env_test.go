@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/axiomhq/pkg/flags"
+)
+
+func TestRun_AppEnvPrefix(t *testing.T) {
+	t.Setenv("ENV_TEST_APP_CMD_TEST_REGION", "eu-west-1")
+	t.Setenv("CMD_TEST_REGION", "us-east-1")
+	t.Setenv("CMD_TEST_API_KEY", "secret")
+	t.Setenv("ENV_TEST_APP_AXIOM_TOKEN", "xapt-5678")
+	t.Setenv("ENV_TEST_APP_AXIOM_SOURCE_TOKEN", "xapt-source")
+	t.Setenv("AXIOM_SOURCE_ORG_ID", "source-org")
+	t.Setenv("FLAG_NEW_INGEST", "false")
+	t.Setenv("ENV_TEST_APP_FLAG_NEW_INGEST", "true")
+
+	set, err := flags.New()
+	require.NoError(t, err)
+	newIngest := set.Bool("new-ingest", false, "")
+
+	core, logs := observer.New(zap.InfoLevel)
+
+	res := RunE("env-test.app", func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		assert.Equal(t, "eu-west-1", Getenv(ctx, "CMD_TEST_REGION"))
+		assert.Equal(t, "secret", Getenv(ctx, "CMD_TEST_API_KEY"))
+		assert.Empty(t, Getenv(ctx, "CMD_TEST_UNSET"))
+		assert.Equal(t, []string{"source"}, Clients(ctx).Names())
+		assert.True(t, newIngest.Enabled())
+		return nil
+	},
+		withTestAxiomOptions(),
+		WithAppEnvPrefix(),
+		WithRequiredEnvVars("CMD_TEST_REGION", "CMD_TEST_API_KEY"),
+		WithAxiomProfiles("source"),
+		WithFlags(set),
+		WithLoggerOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(c, core)
+		})),
+	)
+	assert.Equal(t, ExitOK, res.ExitCode)
+
+	// The values are logged next to the variables they were found in, with
+	// secrets masked.
+	fields := logs.FilterMessage("starting").All()[0].ContextMap()
+	assert.Equal(t, "ENV_TEST_APP_", fields["env_prefix"])
+	assert.Equal(t, map[string]interface{}{
+		"CMD_TEST_REGION":     map[string]interface{}{"value": "eu-west-1", "source": "ENV_TEST_APP_CMD_TEST_REGION"},
+		"CMD_TEST_API_KEY":    map[string]interface{}{"value": "****", "source": "CMD_TEST_API_KEY"},
+		"AXIOM_TOKEN":         map[string]interface{}{"value": "xapt-****", "source": "ENV_TEST_APP_AXIOM_TOKEN"},
+		"AXIOM_SOURCE_TOKEN":  map[string]interface{}{"value": "xapt-****", "source": "ENV_TEST_APP_AXIOM_SOURCE_TOKEN"},
+		"AXIOM_SOURCE_ORG_ID": map[string]interface{}{"value": "source-org", "source": "AXIOM_SOURCE_ORG_ID"},
+		"FLAG_NEW_INGEST":     map[string]interface{}{"value": "true", "source": "ENV_TEST_APP_FLAG_NEW_INGEST"},
+	}, fields["env"])
+
+	// Without the prefix, only unprefixed variables are considered.
+	res = RunE("env-test.app", func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		assert.Equal(t, "us-east-1", Getenv(ctx, "CMD_TEST_REGION"))
+		return nil
+	}, withTestAxiomOptions())
+	assert.Equal(t, ExitOK, res.ExitCode)
+}
+
+func TestMaskSecret(t *testing.T) {
+	assert.Equal(t, "xapt-****", maskSecret("xapt-01234567-89ab"))
+	assert.Equal(t, "****", maskSecret("secret"))
+	assert.Equal(t, "****", maskSecret("xapt-"))
+	assert.Equal(t, "****", maskSecret("-secret"))
+	assert.Equal(t, "****", maskSecret("longer-prefix-secret"))
+}
+
+func TestEnvResolver_AxiomOptions(t *testing.T) {
+	t.Setenv("MY_APP_AXIOM_URL", "http://my-app.axiom.local")
+	t.Setenv("MY_APP_AXIOM_TOKEN", "xapt-5678")
+
+	r := envResolver{prefix: appEnvPrefix("my-app")}
+	assert.Len(t, r.axiomOptions(), 2)
+
+	client, err := axiom.NewClient(append(r.axiomOptions(), axiom.SetNoEnv())...)
+	if assert.NoError(t, err) {
+		assert.NotNil(t, client)
+	}
+
+	assert.Empty(t, envResolver{}.axiomOptions())
+
+	// A lookup function replaces the environment of the process, which the
+	// client must not fall back to: The personal token lacks the organization
+	// ID only set in the environment of the process.
+	t.Setenv("AXIOM_ORG_ID", "process-org")
+	r = envResolver{lookupEnv: func(name string) (string, bool) {
+		if name == "AXIOM_TOKEN" {
+			return "xapt-1234", true
+		}
+		return "", false
+	}}
+	assert.Len(t, r.axiomOptions(), 2)
+
+	_, err = axiom.NewClient(r.axiomOptions()...)
+	assert.EqualError(t, err, "missing organization id")
+}
+
+func TestAppEnvPrefix(t *testing.T) {
+	assert.Equal(t, "MY_APP_", appEnvPrefix("my-app"))
+	assert.Equal(t, "INGESTD_", appEnvPrefix("ingestd"))
+	assert.Equal(t, "V2_API_", appEnvPrefix("v2.api"))
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	"go.uber.org/zap"
+
+	xerrors "github.com/axiomhq/pkg/errors"
 )
 
 // mainFuncError is an error returned by the `MainFunc` that enhances logging
@@ -19,9 +21,9 @@ func (mfe *mainFuncError) Error() string {
 	return fmt.Errorf("%s: %w", mfe.msg, mfe.err).Error()
 }
 
-// Fields returns all `zap.Field` including the error.
-func (mfe *mainFuncError) Fields() []zap.Field {
-	return append(mfe.fields, zap.Error(mfe.err))
+// Unwrap returns the underlying error.
+func (mfe *mainFuncError) Unwrap() error {
+	return mfe.err
 }
 
 // Error is a convenience function that improves error log output when returning
@@ -29,3 +31,47 @@ func (mfe *mainFuncError) Fields() []zap.Field {
 func Error(msg string, err error, fields ...zap.Field) error {
 	return &mainFuncError{msg, err, fields}
 }
+
+// logRunFuncError logs the error returned by the `RunFunc`. If it was composed
+// using `cmd.Error()`, its message is used as the log message. All fields
+// carried by errors in the chain are logged, along with their category and
+// stack trace, if any.
+func logRunFuncError(logger *zap.Logger, appName string, err error) {
+	// Errors of components run by `RunAll()` are logged with the name of the
+	// failed component.
+	var component string
+	if compErr, ok := err.(*ComponentError); ok {
+		component, err = compErr.Component, compErr.Err
+	}
+
+	var (
+		msg    = fmt.Sprintf("%s.RunFunc", appName)
+		fields []zap.Field
+		logErr = err
+	)
+	if component != "" {
+		fields = append(fields, zap.String("component", component))
+	}
+	if mainErr, ok := err.(*mainFuncError); ok {
+		msg, fields, logErr = mainErr.msg, append(fields, mainErr.fields...), mainErr.err
+	}
+
+	seen := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		seen[f.Key] = struct{}{}
+	}
+	for _, f := range xerrors.Fields(err) {
+		if _, ok := seen[f.Key]; !ok {
+			fields = append(fields, f)
+		}
+	}
+
+	if category := xerrors.CategoryOf(err); category != xerrors.Uncategorized {
+		fields = append(fields, zap.Stringer("error_category", category))
+	}
+	if stack := xerrors.Stack(err); stack != "" {
+		fields = append(fields, zap.String("error_stacktrace", stack))
+	}
+
+	logger.Error(msg, append(fields, zap.Error(logErr))...)
+}
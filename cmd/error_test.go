@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	xerrors "github.com/axiomhq/pkg/errors"
+)
+
+func TestLogRunFuncError(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	err := xerrors.New("invalid event", zap.String("dataset", "logs"), zap.Int("line", 1))
+	err = xerrors.WithCategory(err, xerrors.InvalidInput)
+	err = fmt.Errorf("decode: %w", err)
+	err = Error("ingest failed", err, zap.String("tenant", "acme"), zap.Int("line", 2))
+
+	logRunFuncError(zap.New(core), "test", err)
+
+	if assert.Equal(t, 1, logs.Len()) {
+		entry := logs.All()[0]
+		assert.Equal(t, "ingest failed", entry.Message)
+
+		fields := entry.ContextMap()
+		assert.Equal(t, "acme", fields["tenant"])
+		assert.EqualValues(t, 2, fields["line"])
+		assert.Equal(t, "logs", fields["dataset"])
+		assert.Equal(t, "invalid input", fields["error_category"])
+		assert.Contains(t, fields["error_stacktrace"], "cmd.TestLogRunFuncError")
+		assert.Equal(t, "decode: invalid event", fields["error"])
+	}
+}
+
+func TestExitCodeOf(t *testing.T) {
+	for category, code := range map[xerrors.Category]ExitCode{
+		xerrors.Uncategorized: ExitInternal,
+		xerrors.Retryable:     ExitRetryable,
+		xerrors.Permanent:     ExitPermanent,
+		xerrors.InvalidInput:  ExitInvalidInput,
+		xerrors.Unavailable:   ExitUnavailable,
+	} {
+		err := xerrors.WithCategory(errors.New("failed"), category)
+		assert.Equal(t, code, exitCodeOf(err), category.String())
+	}
+}
@@ -1,18 +1,61 @@
 package cmd
 
-import "os"
+import (
+	"os"
 
-// exitCode describes an application exit code.
-type exitCode uint8
+	xerrors "github.com/axiomhq/pkg/errors"
+)
+
+// ExitCode describes an application exit code.
+type ExitCode uint8
 
 // exit the application with the code.
-func (ec exitCode) exit() {
+func (ec ExitCode) exit() {
 	os.Exit(int(ec))
 }
 
 // All available exit codes.
 const (
-	exitOK exitCode = iota
-	exitInternal
-	exitConfig
+	// ExitOK is returned when the application exited gracefully.
+	ExitOK ExitCode = iota
+	// ExitInternal is returned when the `RunFunc` returned an uncategorized
+	// error.
+	ExitInternal
+	// ExitConfig is returned when the application is misconfigured or failed
+	// to bootstrap.
+	ExitConfig
+	// ExitLocked is returned when another instance of the application holds
+	// the instance lock or PID file.
+	ExitLocked
+	// ExitLiveness is returned when the liveness watchdog cancelled the run.
+	ExitLiveness
+	// ExitRetryable is returned when the `RunFunc` returned an error
+	// categorized as `errors.Retryable`.
+	ExitRetryable
+	// ExitPermanent is returned when the `RunFunc` returned an error
+	// categorized as `errors.Permanent`.
+	ExitPermanent
+	// ExitInvalidInput is returned when the `RunFunc` returned an error
+	// categorized as `errors.InvalidInput`.
+	ExitInvalidInput
+	// ExitUnavailable is returned when the `RunFunc` returned an error
+	// categorized as `errors.Unavailable`.
+	ExitUnavailable
 )
+
+// exitCodeOf returns the exit code for an error returned by the `RunFunc`,
+// based on its category. Uncategorized errors result in `ExitInternal`.
+func exitCodeOf(err error) ExitCode {
+	switch xerrors.CategoryOf(err) {
+	case xerrors.Retryable:
+		return ExitRetryable
+	case xerrors.Permanent:
+		return ExitPermanent
+	case xerrors.InvalidInput:
+		return ExitInvalidInput
+	case xerrors.Unavailable:
+		return ExitUnavailable
+	case xerrors.Uncategorized:
+	}
+	return ExitInternal
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+// errHardeningUnsupported is returned when process hardening is not supported
+// by the platform or the binary.
+var errHardeningUnsupported = errors.New("process hardening is not supported")
+
+// hardeningReadPaths are the files and directories the Go resolver and TLS
+// implementation read after startup. They are readable by every policy.
+var hardeningReadPaths = []string{
+	"/etc/hosts",
+	"/etc/nsswitch.conf",
+	"/etc/pki",
+	"/etc/resolv.conf",
+	"/etc/services",
+	"/etc/ssl",
+}
+
+// withDefaultPaths returns the policy with those of the `hardeningReadPaths`
+// and the paths the enabled features of the application access after startup
+// added to it that exist. The directory of profile dumps is passed in, as it
+// defaults to the temporary directory. The PID file and instance lock are
+// removed when the application stops, which requires write access to their
+// directories.
+func (p HardeningPolicy) withDefaultPaths(c *config, profileDumpDir string) HardeningPolicy {
+	readPaths := append([]string(nil), hardeningReadPaths...)
+	if c.flags != nil && c.flags.File() != "" {
+		readPaths = append(readPaths, filepath.Dir(c.flags.File()))
+	}
+	if c.runtimeStatsInterval > 0 {
+		readPaths = append(readPaths, "/proc/self/fd")
+	}
+
+	var writePaths []string
+	for _, path := range []string{c.pidFile, c.instanceLock} {
+		if path != "" {
+			writePaths = append(writePaths, filepath.Dir(path))
+		}
+	}
+	if profileDumpDir != "" {
+		writePaths = append(writePaths, profileDumpDir)
+	}
+
+	p.ReadPaths = append(append([]string(nil), p.ReadPaths...), existingPaths(readPaths)...)
+	p.WritePaths = append(append([]string(nil), p.WritePaths...), existingPaths(writePaths)...)
+	return p
+}
+
+// existingPaths returns the paths that exist.
+func existingPaths(paths []string) []string {
+	var existing []string
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			existing = append(existing, path)
+		}
+	}
+	return existing
+}
+
+// HardeningPolicy describes the restrictions applied to the process once the
+// application started. The restrictions are irreversible and inherited by
+// child processes. Besides the paths of the policy, the application can access
+// the paths its enabled features need after startup: the directory of the
+// flag file configured by `WithFlags()`, "/proc/self/fd" for
+// `WithRuntimeStats()`, the directories of the files configured by
+// `WithPIDFile()` and `WithInstanceLock()` and the directory of
+// `WithProfileDumps()`. Paths the `RunFunc` or hooks access must be part of the
+// policy.
+type HardeningPolicy struct {
+	// ReadPaths are the files and directories the application may read from
+	// and execute. Access to directories extends to everything beneath them.
+	ReadPaths []string
+	// WritePaths are the files and directories the application may read from,
+	// execute, write to and create or remove files in. Access to directories
+	// extends to everything beneath them.
+	WritePaths []string
+	// Required fails the application if the process can't be hardened because
+	// the platform or the binary doesn't support it. Otherwise, a warning is
+	// logged and the application runs without restrictions.
+	Required bool
+}
+
+// hardening describes the restrictions that were applied to the process.
+type hardening struct {
+	noNewPrivs  bool
+	landlockABI int
+}
+
+// fields returns the applied restrictions and the policy as logger fields.
+func (h hardening) fields(policy HardeningPolicy) []zap.Field {
+	return []zap.Field{
+		zap.Bool("no_new_privs", h.noNewPrivs),
+		zap.Bool("landlock", h.landlockABI > 0),
+		zap.Int("landlock_abi", h.landlockABI),
+		zap.Strings("read_paths", policy.ReadPaths),
+		zap.Strings("write_paths", policy.WritePaths),
+	}
+}
@@ -0,0 +1,117 @@
+//go:build linux
+
+package cmd
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// landlockReadAccess are the access rights granted to read paths.
+	landlockReadAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR
+	// landlockWriteAccess are the access rights granted to write paths in
+	// addition to the read access rights.
+	landlockWriteAccess = unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+	// landlockFileAccess are the access rights that apply to files. Rules for
+	// files must not grant any other rights.
+	landlockFileAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE
+)
+
+// harden sets "no_new_privs" and, if supported by the kernel, restricts
+// filesystem access to the paths of the policy using Landlock. Both apply to
+// all threads of the process, which is not supported in binaries using cgo.
+func harden(policy HardeningPolicy) (hardening, error) {
+	var h hardening
+
+	if _, _, errno := syscall.AllThreadsSyscall6(syscall.SYS_PRCTL, unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0, 0); errno == syscall.ENOTSUP {
+		return h, fmt.Errorf("%w: binary uses cgo, build it with CGO_ENABLED=0", errHardeningUnsupported)
+	} else if errno != 0 {
+		return h, fmt.Errorf("set no_new_privs: %w", errno)
+	}
+	h.noNewPrivs = true
+
+	// Landlock is not available if the kernel is too old or it is disabled.
+	abi, _, errno := syscall.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno == syscall.ENOSYS || errno == syscall.EOPNOTSUPP {
+		return h, nil
+	} else if errno != 0 {
+		return h, fmt.Errorf("get landlock abi version: %w", errno)
+	}
+
+	// Only handle the access rights known to both, the kernel and this
+	// package.
+	handled := uint64(landlockReadAccess | landlockWriteAccess)
+	if abi >= 2 {
+		handled |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	rulesetFd, _, errno := syscall.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET,
+		uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return h, fmt.Errorf("create landlock ruleset: %w", errno)
+	}
+	defer syscall.Close(int(rulesetFd))
+
+	for _, path := range policy.ReadPaths {
+		if err := addLandlockRule(int(rulesetFd), path, landlockReadAccess); err != nil {
+			return h, err
+		}
+	}
+	for _, path := range policy.WritePaths {
+		if err := addLandlockRule(int(rulesetFd), path, handled); err != nil {
+			return h, err
+		}
+	}
+
+	if _, _, errno = syscall.AllThreadsSyscall(unix.SYS_LANDLOCK_RESTRICT_SELF, rulesetFd, 0, 0); errno != 0 {
+		return h, fmt.Errorf("apply landlock ruleset: %w", errno)
+	}
+	h.landlockABI = int(abi)
+
+	return h, nil
+}
+
+// addLandlockRule grants the access rights to the file or directory at the
+// given path and everything beneath it.
+func addLandlockRule(rulesetFd int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("allow access to %q: %w", path, err)
+	}
+	defer unix.Close(fd)
+
+	var stat unix.Stat_t
+	if err = unix.Fstat(fd, &stat); err != nil {
+		return fmt.Errorf("allow access to %q: %w", path, err)
+	} else if stat.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= landlockFileAccess
+	}
+
+	attr := unix.LandlockPathBeneathAttr{
+		Allowed_access: access,
+		Parent_fd:      int32(fd),
+	}
+	if _, _, errno := syscall.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(rulesetFd),
+		unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&attr)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("allow access to %q: %w", path, errno)
+	}
+	return nil
+}
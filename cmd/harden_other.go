@@ -0,0 +1,13 @@
+//go:build !linux
+
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// harden is not supported on this platform.
+func harden(HardeningPolicy) (hardening, error) {
+	return hardening{}, fmt.Errorf("%w on %s", errHardeningUnsupported, runtime.GOOS)
+}
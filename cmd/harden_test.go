@@ -0,0 +1,157 @@
+//go:build linux
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/sys/unix"
+
+	"github.com/axiomhq/pkg/flags"
+)
+
+// hardeningTestDirEnv is set to the test directory when the hardening test
+// runs in a child process. Hardening is irreversible, so it must not be
+// applied to the test process itself.
+const hardeningTestDirEnv = "CMD_TEST_HARDENING_DIR"
+
+func TestRun_Hardening(t *testing.T) {
+	dir := os.Getenv(hardeningTestDirEnv)
+	if dir == "" {
+		dir = t.TempDir()
+		for _, name := range []string{"read", "write", "denied", "run"} {
+			require.NoError(t, os.Mkdir(filepath.Join(dir, name), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name, "file"), []byte("content"), 0o644))
+		}
+
+		cmd := exec.Command(os.Args[0], "-test.run=^TestRun_Hardening$", "-test.v")
+		cmd.Env = append(os.Environ(), hardeningTestDirEnv+"="+dir)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+
+		if strings.Contains(string(out), "--- SKIP") {
+			t.Skip("hardening not fully supported, see the output of the child process:\n" + string(out))
+		}
+		return
+	}
+
+	core, logs := observer.New(zap.InfoLevel)
+
+	res := RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error {
+		if logs.FilterMessage("hardening not supported, process is not restricted").Len() > 0 {
+			return nil
+		}
+
+		noNewPrivs, err := unix.PrctlRetInt(unix.PR_GET_NO_NEW_PRIVS, 0, 0, 0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 1, noNewPrivs)
+
+		if logs.FilterMessage("landlock not supported, filesystem access is not restricted").Len() > 0 {
+			return nil
+		}
+
+		_, err = os.ReadFile(filepath.Join(dir, "read", "file"))
+		assert.NoError(t, err)
+		assert.Error(t, os.WriteFile(filepath.Join(dir, "read", "file"), nil, 0o644))
+
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "write", "new"), nil, 0o644))
+
+		_, err = os.ReadFile(filepath.Join(dir, "denied", "file"))
+		assert.ErrorIs(t, err, os.ErrPermission)
+
+		// The files of the resolver stay readable.
+		for _, path := range []string{"/etc/hosts", "/etc/resolv.conf"} {
+			if _, err = os.ReadFile(path); !errors.Is(err, os.ErrNotExist) {
+				assert.NoError(t, err, path)
+			}
+		}
+
+		return nil
+	},
+		withTestAxiomOptions(),
+		WithLoggerOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(c, core)
+		})),
+		WithPIDFile(filepath.Join(dir, "run", "test.pid")),
+		WithHardening(HardeningPolicy{
+			ReadPaths:  []string{filepath.Join(dir, "read")},
+			WritePaths: []string{filepath.Join(dir, "write")},
+		}),
+	)
+	require.Equal(t, ExitOK, res.ExitCode)
+
+	// The PID file is removed, as its directory is added to the policy.
+	assert.NoFileExists(t, filepath.Join(dir, "run", "test.pid"))
+
+	if logs.FilterMessage("hardening not supported, process is not restricted").Len() > 0 {
+		// Binaries using cgo can't be hardened, which is tolerated unless the
+		// policy is required.
+		res = RunE("test", nil, withTestAxiomOptions(), WithHardening(HardeningPolicy{Required: true}))
+		require.Equal(t, ExitConfig, res.ExitCode)
+		require.True(t, errors.Is(res.Err, errHardeningUnsupported))
+		t.Skip("hardening requires a binary built with CGO_ENABLED=0")
+	}
+
+	if logs.FilterMessage("landlock not supported, filesystem access is not restricted").Len() > 0 {
+		t.Skip("landlock not supported")
+	}
+
+	if entries := logs.FilterMessage("hardened").All(); assert.Len(t, entries, 1) {
+		fields := entries[0].ContextMap()
+		assert.Equal(t, true, fields["no_new_privs"])
+		assert.Equal(t, true, fields["landlock"])
+	}
+}
+
+func TestHardeningPolicy_WithDefaultPaths(t *testing.T) {
+	dir := t.TempDir()
+
+	set, err := flags.New(flags.WithFile(filepath.Join(dir, "flags", "flags.json")))
+	require.NoError(t, err)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "flags"), 0o755))
+
+	cfg := &config{}
+	for _, option := range []Option{
+		WithFlags(set),
+		WithRuntimeStats(time.Minute),
+		WithPIDFile(filepath.Join(dir, "test.pid")),
+		WithInstanceLock(filepath.Join(dir, "missing", "test.lock")),
+	} {
+		require.NoError(t, option(cfg))
+	}
+
+	policy := HardeningPolicy{ReadPaths: []string{"read"}, WritePaths: []string{"write"}}
+	got := policy.withDefaultPaths(cfg, filepath.Join(dir, "dumps"))
+	assert.Equal(t, []string{"read"}, policy.ReadPaths)
+	assert.Equal(t, []string{"write"}, policy.WritePaths)
+
+	// Paths that don't exist are left out.
+	assert.Equal(t, "read", got.ReadPaths[0])
+	assert.Contains(t, got.ReadPaths, filepath.Join(dir, "flags"))
+	assert.Contains(t, got.ReadPaths, "/proc/self/fd")
+	assert.Equal(t, []string{"write", dir}, got.WritePaths)
+}
+
+func TestRun_Hardening_InvalidPath(t *testing.T) {
+	if os.Getenv(hardeningTestDirEnv) != "" {
+		t.Skip("running as child process")
+	}
+
+	res := RunE("test", nil, withTestAxiomOptions(), WithHardening(HardeningPolicy{
+		ReadPaths: []string{""},
+	}))
+	assert.Equal(t, ExitConfig, res.ExitCode)
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"go.uber.org/zap"
+)
+
+// Hooks are functions called at certain points of the application lifecycle.
+// They allow sharing concerns like registering metrics, warming caches or
+// flushing buffers between applications. Any of them can be nil.
+type Hooks struct {
+	// BeforeRun is called after the application started up and before the
+	// `RunFunc` is called. If it returns an error, the `RunFunc` is not called
+	// and the application exits with an exit code based on the category of
+	// the error.
+	BeforeRun func(context.Context, *zap.Logger, *axiom.Client) error
+	// AfterRun is called after the `RunFunc` returned, if the `BeforeRun` hook
+	// of the same hooks succeeded. The context passed to it is never marked
+	// done. If it returns an error and the run was successful, the application
+	// exits with an exit code based on the category of the error.
+	AfterRun func(context.Context, *zap.Logger, *axiom.Client) error
+	// OnSignal is called when an exit signal arrives.
+	OnSignal func(context.Context, *zap.Logger, *axiom.Client, os.Signal)
+	// OnError is called with the error returned by the `RunFunc`, if any.
+	OnError func(context.Context, *zap.Logger, *axiom.Client, error)
+}
+
+var (
+	globalHooksMu sync.Mutex
+	globalHooks   []Hooks
+)
+
+// RegisterHooks registers hooks for all applications run by this process. It
+// is meant to be called by shared libraries, usually from an `init()`
+// function. Globally registered hooks are called before the ones passed using
+// the `WithHooks()` option, in order of registration.
+func RegisterHooks(hooks Hooks) {
+	globalHooksMu.Lock()
+	defer globalHooksMu.Unlock()
+
+	globalHooks = append(globalHooks, hooks)
+}
+
+// registeredHooks returns a copy of the globally registered hooks.
+func registeredHooks() []Hooks {
+	globalHooksMu.Lock()
+	defer globalHooksMu.Unlock()
+
+	return append([]Hooks(nil), globalHooks...)
+}
+
+// hookChain is an ordered list of hooks.
+type hookChain []Hooks
+
+// beforeRun calls the `BeforeRun` hooks in order until one fails. It returns
+// the number of hooks that succeeded.
+func (hc hookChain) beforeRun(ctx context.Context, logger *zap.Logger, client *axiom.Client) (int, error) {
+	for i, h := range hc {
+		if h.BeforeRun == nil {
+			continue
+		}
+		if err := h.BeforeRun(ctx, logger, client); err != nil {
+			logger.Error("before run hook", zap.Error(err), zap.Int("hook", i))
+			return i, err
+		}
+	}
+	return len(hc), nil
+}
+
+// afterRun calls the `AfterRun` hooks of the first n hooks in reverse order,
+// so teardown mirrors setup. All hooks are called, even if one fails. The
+// first error is returned.
+func (hc hookChain) afterRun(ctx context.Context, logger *zap.Logger, client *axiom.Client, n int) (err error) {
+	ctx = uncancelledContext{ctx}
+	for i := n - 1; i >= 0; i-- {
+		if hc[i].AfterRun == nil {
+			continue
+		}
+		if hookErr := hc[i].AfterRun(ctx, logger, client); hookErr != nil {
+			logger.Error("after run hook", zap.Error(hookErr), zap.Int("hook", i))
+			if err == nil {
+				err = hookErr
+			}
+		}
+	}
+	return err
+}
+
+// onSignal calls the `OnSignal` hooks in order.
+func (hc hookChain) onSignal(ctx context.Context, logger *zap.Logger, client *axiom.Client, sig os.Signal) {
+	for _, h := range hc {
+		if h.OnSignal != nil {
+			h.OnSignal(ctx, logger, client, sig)
+		}
+	}
+}
+
+// onError calls the `OnError` hooks in order.
+func (hc hookChain) onError(ctx context.Context, logger *zap.Logger, client *axiom.Client, err error) {
+	for _, h := range hc {
+		if h.OnError != nil {
+			h.OnError(ctx, logger, client, err)
+		}
+	}
+}
+
+// uncancelledContext is a context that carries the values of its parent but
+// is never marked done.
+type uncancelledContext struct {
+	context.Context
+}
+
+// Deadline implements `context.Context`.
+func (uncancelledContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+// Done implements `context.Context`.
+func (uncancelledContext) Done() <-chan struct{} { return nil }
+
+// Err implements `context.Context`.
+func (uncancelledContext) Err() error { return nil }
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	xerrors "github.com/axiomhq/pkg/errors"
+	"github.com/axiomhq/pkg/scheduler"
+)
+
+// recordingHooks returns hooks which record their calls, prefixed by the
+// given name.
+func recordingHooks(name string, calls *[]string) Hooks {
+	return Hooks{
+		BeforeRun: func(context.Context, *zap.Logger, *axiom.Client) error {
+			*calls = append(*calls, name+".BeforeRun")
+			return nil
+		},
+		AfterRun: func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+			*calls = append(*calls, name+".AfterRun")
+			if ctx.Err() != nil {
+				return errors.New("context marked done")
+			}
+			return nil
+		},
+		OnError: func(_ context.Context, _ *zap.Logger, _ *axiom.Client, err error) {
+			*calls = append(*calls, name+".OnError: "+err.Error())
+		},
+	}
+}
+
+func withCleanGlobalHooks(t *testing.T) {
+	t.Helper()
+
+	globalHooksMu.Lock()
+	globalHooks = nil
+	globalHooksMu.Unlock()
+
+	t.Cleanup(func() {
+		globalHooksMu.Lock()
+		globalHooks = nil
+		globalHooksMu.Unlock()
+	})
+}
+
+func TestRun_Hooks(t *testing.T) {
+	withCleanGlobalHooks(t)
+
+	var calls []string
+	RegisterHooks(recordingHooks("global", &calls))
+
+	res := RunE("test", func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		calls = append(calls, "RunFunc")
+		return errors.New("failed")
+	}, withTestAxiomOptions(), WithHooks(recordingHooks("a", &calls), recordingHooks("b", &calls)))
+
+	assert.Equal(t, ExitInternal, res.ExitCode)
+	assert.Equal(t, []string{
+		"global.BeforeRun",
+		"a.BeforeRun",
+		"b.BeforeRun",
+		"RunFunc",
+		"global.OnError: failed",
+		"a.OnError: failed",
+		"b.OnError: failed",
+		"b.AfterRun",
+		"a.AfterRun",
+		"global.AfterRun",
+	}, calls)
+}
+
+func TestRun_Hooks_BeforeRunError(t *testing.T) {
+	withCleanGlobalHooks(t)
+
+	var calls []string
+	failing := Hooks{
+		BeforeRun: func(context.Context, *zap.Logger, *axiom.Client) error {
+			return xerrors.WithCategory(errors.New("cache unavailable"), xerrors.Unavailable)
+		},
+		AfterRun: func(context.Context, *zap.Logger, *axiom.Client) error {
+			require.FailNow(t, "must not be called")
+			return nil
+		},
+	}
+
+	res := RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error {
+		require.FailNow(t, "must not be called")
+		return nil
+	}, withTestAxiomOptions(), WithHooks(recordingHooks("a", &calls), failing, recordingHooks("b", &calls)))
+
+	assert.Equal(t, ExitUnavailable, res.ExitCode)
+	assert.EqualError(t, res.Err, "cache unavailable")
+	assert.Equal(t, []string{"a.BeforeRun", "a.AfterRun"}, calls)
+}
+
+func TestRun_Hooks_AfterRunError(t *testing.T) {
+	withCleanGlobalHooks(t)
+
+	res := RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error {
+		return nil
+	}, withTestAxiomOptions(), WithHooks(Hooks{
+		AfterRun: func(context.Context, *zap.Logger, *axiom.Client) error {
+			return xerrors.WithCategory(errors.New("flush failed"), xerrors.Retryable)
+		},
+	}))
+
+	assert.Equal(t, ExitRetryable, res.ExitCode)
+	assert.EqualError(t, res.Err, "flush failed")
+}
+
+func TestRun_Hooks_OnSignal(t *testing.T) {
+	withCleanGlobalHooks(t)
+
+	sigCh := make(chan os.Signal, 1)
+	res := RunE("test", func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		p, err := os.FindProcess(os.Getpid())
+		require.NoError(t, err)
+		require.NoError(t, p.Signal(syscall.SIGHUP))
+
+		<-ctx.Done()
+		return nil
+	}, withTestAxiomOptions(), WithExitSignals(syscall.SIGHUP), WithHooks(Hooks{
+		OnSignal: func(_ context.Context, _ *zap.Logger, _ *axiom.Client, sig os.Signal) {
+			sigCh <- sig
+		},
+	}))
+	assert.Equal(t, ExitOK, res.ExitCode)
+
+	select {
+	case sig := <-sigCh:
+		assert.Equal(t, syscall.SIGHUP, sig)
+	case <-time.After(time.Second * 5):
+		require.FailNow(t, "signal hook not called")
+	}
+}
+
+func TestRun_Hooks_BeforeJobs(t *testing.T) {
+	var prepared, early int32
+	ran := make(chan struct{}, 1)
+
+	res := RunE("test", func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		select {
+		case <-ran:
+		case <-time.After(time.Second * 5):
+			require.FailNow(t, "job not run")
+		}
+		return nil
+	},
+		withTestAxiomOptions(),
+		WithJobs(scheduler.Job{
+			Name:     "warm",
+			Schedule: scheduler.Every(time.Millisecond),
+			Func: func(context.Context) error {
+				if atomic.LoadInt32(&prepared) == 0 {
+					atomic.StoreInt32(&early, 1)
+				}
+				select {
+				case ran <- struct{}{}:
+				default:
+				}
+				return nil
+			},
+		}),
+		WithHooks(Hooks{
+			BeforeRun: func(context.Context, *zap.Logger, *axiom.Client) error {
+				// Give a job started too early the chance to run.
+				time.Sleep(time.Millisecond * 20)
+				atomic.StoreInt32(&prepared, 1)
+				return nil
+			},
+		}),
+	)
+	assert.Equal(t, ExitOK, res.ExitCode)
+	assert.Zero(t, atomic.LoadInt32(&early), "job ran before the BeforeRun hooks")
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// Invocation describes how the application was invoked. It is carried by the
+// context passed to the `RunFunc` and can be read using `InvocationFrom()`.
+// Tests can substitute its arguments, streams and environment using the
+// `WithArgs()`, `WithIO()` and `WithEnvLookup()` options.
+type Invocation struct {
+	// Args are the command line arguments, without the program name. They are
+	// passed as is, unless the application declares its command line flags
+	// using `WithCommandLine()`, which leaves the positional arguments that
+	// remain after parsing them.
+	Args []string
+	// Stdin is the standard input of the application.
+	Stdin io.Reader
+	// Stdout is the standard output of the application.
+	Stdout io.Writer
+	// Stderr is the standard error output of the application. It is not used
+	// by the logger.
+	Stderr io.Writer
+
+	env envResolver
+}
+
+// defaultInvocation returns the invocation of the running process.
+func defaultInvocation() Invocation {
+	var args []string
+	if len(os.Args) > 1 {
+		args = os.Args[1:]
+	}
+	return Invocation{
+		Args:   args,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// LookupEnv looks up the environment variable. See `Getenv()` for details.
+func (inv *Invocation) LookupEnv(name string) (string, bool) {
+	value, _, ok := inv.env.lookup(name)
+	return value, ok
+}
+
+// Getenv returns the value of the environment variable, which is empty if it
+// is not set. See `Getenv()` for details.
+func (inv *Invocation) Getenv(name string) string {
+	return inv.env.getenv(name)
+}
+
+type invocationKey struct{}
+
+// InvocationFrom returns the invocation carried by the context. If there is
+// none, the invocation of the running process is returned.
+func InvocationFrom(ctx context.Context) *Invocation {
+	if inv, ok := ctx.Value(invocationKey{}).(*Invocation); ok {
+		return inv
+	}
+	inv := defaultInvocation()
+	return &inv
+}
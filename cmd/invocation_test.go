@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRun_Invocation(t *testing.T) {
+	env := map[string]string{
+		"AXIOM_URL":         "http://axiom.local",
+		"AXIOM_TOKEN":       "xapt-1234",
+		"CMD_TEST_REQUIRED": "set",
+		"TEST_GREETING":     "hello",
+	}
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	var (
+		stdin          = strings.NewReader("world\n")
+		stdout, stderr bytes.Buffer
+	)
+
+	res := RunE("test", func(ctx context.Context, _ *zap.Logger, client *axiom.Client) error {
+		require.NotNil(t, client)
+
+		inv := InvocationFrom(ctx)
+		assert.Equal(t, []string{"greet", "--loud"}, inv.Args)
+
+		in, err := io.ReadAll(inv.Stdin)
+		require.NoError(t, err)
+
+		_, err = io.WriteString(inv.Stdout, Getenv(ctx, "TEST_GREETING")+" "+string(in))
+		require.NoError(t, err)
+		_, err = io.WriteString(inv.Stderr, "done\n")
+		require.NoError(t, err)
+
+		_, ok := inv.LookupEnv("CMD_TEST_UNSET")
+		assert.False(t, ok)
+
+		return nil
+	},
+		WithArgs("greet", "--loud"),
+		WithIO(stdin, &stdout, &stderr),
+		WithEnvLookup(lookupEnv),
+		WithRequiredEnvVars("CMD_TEST_REQUIRED"),
+	)
+	require.Equal(t, ExitOK, res.ExitCode)
+
+	assert.Equal(t, "hello world\n", stdout.String())
+	assert.Equal(t, "done\n", stderr.String())
+
+	// Required environment variables are looked up using the function.
+	delete(env, "CMD_TEST_REQUIRED")
+	res = RunE("test", nil, WithEnvLookup(lookupEnv), WithRequiredEnvVars("CMD_TEST_REQUIRED"))
+	assert.Equal(t, ExitConfig, res.ExitCode)
+}
+
+func TestInvocationFrom_Default(t *testing.T) {
+	inv := InvocationFrom(context.Background())
+	assert.Equal(t, os.Args[1:], inv.Args)
+	assert.Equal(t, os.Stdin, inv.Stdin)
+	assert.Equal(t, os.Stdout, inv.Stdout)
+	assert.Equal(t, os.Stderr, inv.Stderr)
+
+	t.Setenv("CMD_TEST_INVOCATION", "set")
+	assert.Equal(t, "set", inv.Getenv("CMD_TEST_INVOCATION"))
+}
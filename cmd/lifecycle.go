@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"go.uber.org/zap"
+
+	"github.com/axiomhq/pkg/version"
+)
+
+const (
+	// defaultLifecycleTimeout is the default time spent delivering outstanding
+	// lifecycle events on shutdown.
+	defaultLifecycleTimeout = time.Second * 5
+	// lifecycleQueueSize is the number of lifecycle events that can be queued
+	// for delivery. Events are dropped if the queue is full.
+	lifecycleQueueSize = 16
+)
+
+// lifecycleReporter ingests lifecycle events of the application into Axiom.
+// Events are queued and delivered asynchronously, so a slow connection doesn't
+// delay the application. All of its methods are no-ops on a nil reporter.
+type lifecycleReporter struct {
+	logger  *zap.Logger
+	dataset string
+	timeout time.Duration
+
+	startTime time.Time
+	base      axiom.Event
+
+	eventCh chan axiom.Event
+	doneCh  chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	startOnce    sync.Once
+	stoppingOnce sync.Once
+}
+
+// newLifecycleReporter creates a new lifecycle reporter which ingests events
+// into the given dataset. Outstanding events are delivered on close for at
+// most the given timeout.
+func newLifecycleReporter(logger *zap.Logger, appName, dataset string, timeout time.Duration, startTime time.Time) *lifecycleReporter {
+	if timeout <= 0 {
+		timeout = defaultLifecycleTimeout
+	}
+
+	hostname, _ := os.Hostname()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &lifecycleReporter{
+		logger:  logger.Named("lifecycle"),
+		dataset: dataset,
+		timeout: timeout,
+
+		startTime: startTime,
+		base: axiom.Event{
+			"app":        appName,
+			"hostname":   hostname,
+			"pid":        os.Getpid(),
+			"release":    version.Release(),
+			"revision":   version.Revision(),
+			"build_date": version.BuildDateString(),
+			"build_user": version.BuildUser(),
+			"go_version": version.GoVersion(),
+		},
+
+		eventCh: make(chan axiom.Event, lifecycleQueueSize),
+		doneCh:  make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// report queues the named lifecycle event with the given additional fields.
+func (r *lifecycleReporter) report(name string, fields axiom.Event) {
+	if r == nil {
+		return
+	}
+
+	event := make(axiom.Event, len(r.base)+len(fields)+3)
+	for k, v := range r.base {
+		event[k] = v
+	}
+	for k, v := range fields {
+		event[k] = v
+	}
+	event[axiom.TimestampField] = time.Now()
+	event["event"] = name
+	event["uptime_seconds"] = time.Since(r.startTime).Seconds()
+
+	select {
+	case r.eventCh <- event:
+	default:
+		r.logger.Warn("lifecycle event queue full, dropping event", zap.String("event", name))
+	}
+}
+
+// stopping reports that the application is shutting down. Only the first call
+// has an effect.
+func (r *lifecycleReporter) stopping() {
+	if r == nil {
+		return
+	}
+	r.stoppingOnce.Do(func() { r.report("stopping", nil) })
+}
+
+// stopped reports that the application stopped with the given result. A
+// result with a non-zero exit code is reported as a crash.
+func (r *lifecycleReporter) stopped(res Result) {
+	name := "stopped"
+	if res.ExitCode != ExitOK {
+		name = "crashed"
+	}
+
+	r.report(name, axiom.Event{
+		"exit_code":       uint8(res.ExitCode),
+		"shutdown_reason": shutdownReason(res),
+	})
+}
+
+// panicked reports that the application crashed because of a panic.
+func (r *lifecycleReporter) panicked(v interface{}, stack []byte) {
+	r.report("crashed", axiom.Event{
+		"shutdown_reason": fmt.Sprintf("panic: %v", v),
+		"stacktrace":      string(stack),
+	})
+}
+
+// start delivering queued events using the given client. Only the first call
+// has an effect.
+func (r *lifecycleReporter) start(client *axiom.Client) {
+	if r == nil {
+		return
+	}
+	r.startOnce.Do(func() { go r.deliver(client) })
+}
+
+// deliver ingests queued events in batches until the queue is closed.
+func (r *lifecycleReporter) deliver(client *axiom.Client) {
+	defer close(r.doneCh)
+
+	for event := range r.eventCh {
+		events := []axiom.Event{event}
+	batch:
+		for {
+			select {
+			case event, ok := <-r.eventCh:
+				if !ok {
+					break batch
+				}
+				events = append(events, event)
+			default:
+				break batch
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.ctx, r.timeout)
+		_, err := client.Datasets.IngestEvents(ctx, r.dataset, axiom.IngestOptions{}, events...)
+		cancel()
+		if err != nil {
+			r.logger.Error("ingest lifecycle events", zap.Error(err), zap.String("dataset", r.dataset))
+		}
+	}
+}
+
+// close the reporter and wait for outstanding events to be delivered, but no
+// longer than the configured timeout. Events that can't be delivered in time
+// are dropped.
+func (r *lifecycleReporter) close() {
+	if r == nil {
+		return
+	}
+	defer r.cancel()
+
+	close(r.eventCh)
+
+	// Events can't be delivered if the Axiom client was never created.
+	delivering := true
+	r.startOnce.Do(func() { delivering = false })
+	if !delivering {
+		return
+	}
+
+	select {
+	case <-r.doneCh:
+	case <-time.After(r.timeout):
+		r.logger.Warn("lifecycle events not delivered in time", zap.Duration("timeout", r.timeout))
+	}
+}
+
+// shutdownReason describes why the application shut down.
+func shutdownReason(res Result) string {
+	switch {
+	case res.Signal != nil:
+		return "signal: " + res.Signal.String()
+	case res.Err != nil:
+		return "error: " + res.Err.Error()
+	}
+	return "completed"
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRun_LifecycleEvents(t *testing.T) {
+	axiomOptions, eventCh := withTestIngestServer(t)
+
+	fn := func(context.Context, *zap.Logger, *axiom.Client) error {
+		return errors.New("boom")
+	}
+
+	res := RunE("audited", fn,
+		axiomOptions,
+		WithLifecycleEvents("lifecycle", time.Second*5),
+	)
+	assert.Equal(t, ExitInternal, res.ExitCode)
+
+	// All events are flushed before `RunE` returns.
+	var events []axiom.Event
+	for len(eventCh) > 0 {
+		ingested := <-eventCh
+		assert.Equal(t, "lifecycle", ingested.dataset)
+		events = append(events, ingested.event)
+	}
+	require.Len(t, events, 4)
+
+	for i, name := range []string{"starting", "started", "stopping", "crashed"} {
+		assert.Equal(t, name, events[i]["event"])
+		assert.Equal(t, "audited", events[i]["app"])
+		assert.Contains(t, events[i], "hostname")
+		assert.Contains(t, events[i], "pid")
+		assert.Contains(t, events[i], "release")
+		assert.Contains(t, events[i], "uptime_seconds")
+	}
+	assert.EqualValues(t, ExitInternal, events[3]["exit_code"])
+	assert.Equal(t, "error: boom", events[3]["shutdown_reason"])
+}
+
+func TestLifecycleReporter_BoundedClose(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client, err := axiom.NewClient(
+		axiom.SetNoEnv(),
+		axiom.SetURL(srv.URL),
+		axiom.SetAccessToken("xapt-1234"),
+	)
+	require.NoError(t, err)
+
+	r := newLifecycleReporter(zap.NewNop(), "slow", "lifecycle", time.Millisecond*50, time.Now())
+	r.start(client)
+	r.report("starting", nil)
+
+	start := time.Now()
+	r.close()
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestShutdownReason(t *testing.T) {
+	assert.Equal(t, "completed", shutdownReason(Result{}))
+	assert.Equal(t, "error: boom", shutdownReason(Result{Err: errors.New("boom")}))
+	assert.Equal(t, "signal: interrupt", shutdownReason(Result{Signal: interruptSignal{}}))
+}
+
+type interruptSignal struct{}
+
+func (interruptSignal) String() string { return "interrupt" }
+func (interruptSignal) Signal()        {}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// minLivenessCheckInterval is the minimum interval at which the liveness
+// watchdog checks the heartbeats. It is usually a quarter of the shortest
+// deadline.
+const minLivenessCheckInterval = time.Millisecond
+
+// LivenessPolicy describes how the liveness watchdog reacts to a loop missing
+// its heartbeat deadline.
+type LivenessPolicy uint8
+
+// All available liveness policies.
+const (
+	// LivenessFailHealth marks the application as unhealthy until the loop
+	// reports a heartbeat again. The health state is reported to the service
+	// manager by withholding watchdog pings and the application is not ready,
+	// as reported by `IsReady()` and `ReadinessHandler()`.
+	LivenessFailHealth LivenessPolicy = iota
+	// LivenessExit cancels the context passed to the `RunFunc` and exits the
+	// application with a dedicated exit code once the `RunFunc` returned.
+	LivenessExit
+)
+
+// String returns the string representation of the liveness policy.
+func (lp LivenessPolicy) String() string {
+	switch lp {
+	case LivenessFailHealth:
+		return "fail-health"
+	case LivenessExit:
+		return "exit"
+	}
+	return fmt.Sprintf("LivenessPolicy(%d)", lp)
+}
+
+// livenessWatchdog monitors the heartbeats of long running loops and reacts to
+// loops that missed their deadline according to its policy.
+type livenessWatchdog struct {
+	logger          *zap.Logger
+	policy          LivenessPolicy
+	defaultDeadline time.Duration
+	deadlines       map[string]time.Duration
+	cancel          context.CancelFunc
+
+	mu     sync.Mutex
+	beats  map[string]time.Time
+	missed map[string]struct{}
+
+	// tripErr is set once the watchdog cancelled the run.
+	tripErr error
+}
+
+// newLivenessWatchdog creates a new liveness watchdog. Loops with a configured
+// deadline are monitored right away, all other loops after their first
+// heartbeat.
+func newLivenessWatchdog(logger *zap.Logger, policy LivenessPolicy, defaultDeadline time.Duration, deadlines map[string]time.Duration, cancel context.CancelFunc) *livenessWatchdog {
+	w := &livenessWatchdog{
+		logger:          logger.Named("liveness"),
+		policy:          policy,
+		defaultDeadline: defaultDeadline,
+		deadlines:       deadlines,
+		cancel:          cancel,
+
+		beats:  make(map[string]time.Time, len(deadlines)),
+		missed: make(map[string]struct{}),
+	}
+
+	now := time.Now()
+	for name := range deadlines {
+		w.beats[name] = now
+	}
+
+	return w
+}
+
+// deadline returns the deadline of the named loop.
+func (w *livenessWatchdog) deadline(name string) time.Duration {
+	if d, ok := w.deadlines[name]; ok {
+		return d
+	}
+	return w.defaultDeadline
+}
+
+// heartbeat records a heartbeat of the named loop.
+func (w *livenessWatchdog) heartbeat(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.beats[name] = time.Now()
+	if _, ok := w.missed[name]; ok {
+		delete(w.missed, name)
+		w.logger.Info("loop recovered", zap.String("loop", name))
+	}
+}
+
+// run checks the heartbeats periodically. It blocks until the context is marked
+// done.
+func (w *livenessWatchdog) run(ctx context.Context) {
+	interval := w.defaultDeadline
+	for _, d := range w.deadlines {
+		if d < interval {
+			interval = d
+		}
+	}
+
+	interval /= 4
+	if interval < minLivenessCheckInterval {
+		interval = minLivenessCheckInterval
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			w.check(now)
+		}
+	}
+}
+
+// check reacts to all loops that missed their deadline since the last check.
+func (w *livenessWatchdog) check(now time.Time) {
+	w.mu.Lock()
+	var stuck []string
+	for name, last := range w.beats {
+		if _, ok := w.missed[name]; ok || now.Sub(last) <= w.deadline(name) {
+			continue
+		}
+		w.missed[name] = struct{}{}
+		stuck = append(stuck, name)
+	}
+	w.mu.Unlock()
+
+	if len(stuck) == 0 {
+		return
+	}
+	sort.Strings(stuck)
+
+	w.logger.Error("loop missed heartbeat deadline",
+		zap.Strings("loops", stuck),
+		zap.Stringer("policy", w.policy),
+		zap.String("goroutines", goroutineDump()),
+	)
+
+	if w.policy != LivenessExit {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.tripErr == nil {
+		w.tripErr = fmt.Errorf("liveness watchdog cancelled run: loops missed heartbeat deadline: %s",
+			strings.Join(stuck, ", "))
+		w.cancel()
+	}
+}
+
+// healthy returns an error if any loop missed its deadline.
+func (w *livenessWatchdog) healthy(context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.missed) == 0 {
+		return nil
+	}
+
+	stuck := make([]string, 0, len(w.missed))
+	for name := range w.missed {
+		stuck = append(stuck, name)
+	}
+	sort.Strings(stuck)
+
+	return fmt.Errorf("loops missed heartbeat deadline: %s", strings.Join(stuck, ", "))
+}
+
+// tripError returns the reason the watchdog cancelled the run. It returns nil
+// if the run wasn't cancelled by the watchdog.
+func (w *livenessWatchdog) tripError() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.tripErr
+}
+
+// goroutineDump returns the stack traces of all goroutines.
+func goroutineDump() string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+type livenessWatchdogKey struct{}
+
+// Heartbeat reports that the named loop is still making progress. Long running
+// loops should call it on every iteration. If the liveness watchdog is enabled
+// using the `WithLivenessWatchdog()` option, a loop is monitored after its
+// first heartbeat and considered stuck if it doesn't report a heartbeat within
+// its deadline. The context must be the one passed to the `RunFunc`.
+func Heartbeat(ctx context.Context, name string) {
+	if w, ok := ctx.Value(livenessWatchdogKey{}).(*livenessWatchdog); ok {
+		w.heartbeat(name)
+	}
+}
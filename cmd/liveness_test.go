@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLivenessWatchdog(t *testing.T) {
+	w := newLivenessWatchdog(zap.NewNop(), LivenessFailHealth, time.Second,
+		map[string]time.Duration{"configured": time.Minute}, nil)
+
+	start := time.Now()
+	w.heartbeat("loop")
+
+	w.check(start.Add(time.Second / 2))
+	assert.NoError(t, w.healthy(context.Background()))
+
+	w.check(start.Add(time.Second * 2))
+	assert.EqualError(t, w.healthy(context.Background()), "loops missed heartbeat deadline: loop")
+
+	w.check(start.Add(time.Minute * 2))
+	assert.EqualError(t, w.healthy(context.Background()), "loops missed heartbeat deadline: configured, loop")
+
+	w.heartbeat("loop")
+	w.heartbeat("configured")
+	assert.NoError(t, w.healthy(context.Background()))
+	assert.NoError(t, w.tripError())
+}
+
+func TestRun_LivenessExit(t *testing.T) {
+	fn := func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		Heartbeat(ctx, "loop")
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Second * 5):
+			require.FailNow(t, "context not cancelled by liveness watchdog")
+		}
+		return nil
+	}
+
+	res := RunE("test", fn,
+		withTestAxiomOptions(),
+		WithLivenessWatchdog(time.Millisecond*50, LivenessExit),
+	)
+	assert.Equal(t, ExitLiveness, res.ExitCode)
+}
+
+func TestRun_LivenessFailHealth(t *testing.T) {
+	fn := func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		Heartbeat(ctx, "loop")
+		require.True(t, IsReady(ctx))
+
+		// The application is not ready while the loop is stuck.
+		require.Eventually(t, func() bool { return !IsReady(ctx) }, time.Second*5, time.Millisecond)
+
+		Heartbeat(ctx, "loop")
+		assert.True(t, IsReady(ctx))
+
+		return nil
+	}
+
+	res := RunE("test", fn,
+		withTestAxiomOptions(),
+		WithLivenessWatchdog(time.Millisecond*50, LivenessFailHealth),
+	)
+	assert.Equal(t, ExitOK, res.ExitCode)
+}
+
+func TestRun_LivenessTinyDeadline(t *testing.T) {
+	res := RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error {
+		time.Sleep(time.Millisecond * 5)
+		return nil
+	}, withTestAxiomOptions(), WithLivenessWatchdog(time.Nanosecond, LivenessFailHealth))
+	assert.Equal(t, ExitOK, res.ExitCode)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// lockedError is returned when a lock file is held by another instance of the
+// application.
+type lockedError struct {
+	path string
+	pid  int
+}
+
+// Error implements `error`.
+func (le *lockedError) Error() string {
+	if le.pid > 0 {
+		return fmt.Sprintf("another instance (pid %d) holds the lock on %q", le.pid, le.path)
+	}
+	return fmt.Sprintf("another instance holds the lock on %q", le.path)
+}
+
+// lockFile is a file exclusively locked by the running process for the
+// lifetime of the application. It backs both, PID files and instance locks.
+type lockFile struct {
+	f    *os.File
+	path string
+
+	// writePID indicates that the process ID is written to the file and the
+	// file is removed when the lock is released.
+	writePID bool
+	// stalePID is the process ID found in the file at the time the lock was
+	// acquired. As the lock was acquired, the process is no longer running.
+	stalePID int
+}
+
+// readPID reads the process ID from the given reader. Zero is returned if the
+// reader doesn't contain a valid process ID.
+func readPID(r io.Reader) int {
+	b, err := io.ReadAll(io.LimitReader(r, 32))
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(b)))
+	if err != nil || pid <= 0 {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,19 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package cmd
+
+import "errors"
+
+// errLockUnsupported is returned when lock files are used on a platform that
+// doesn't support them.
+var errLockUnsupported = errors.New("lock files are not supported on this platform")
+
+// acquireLockFile is not supported on this platform.
+func acquireLockFile(string, bool) (*lockFile, error) {
+	return nil, errLockUnsupported
+}
+
+// release is not supported on this platform.
+func (lf *lockFile) release() error {
+	return errLockUnsupported
+}
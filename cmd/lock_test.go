@@ -0,0 +1,81 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAcquireLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+
+	lock, err := acquireLockFile(path, true)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(os.Getpid()), strings.TrimSpace(string(b)))
+
+	_, err = acquireLockFile(path, true)
+	if assert.IsType(t, &lockedError{}, err) {
+		assert.Equal(t, os.Getpid(), err.(*lockedError).pid)
+	}
+
+	require.NoError(t, lock.release())
+	assert.NoFileExists(t, path)
+}
+
+func TestAcquireLockFile_Stale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	require.NoError(t, os.WriteFile(path, []byte("4194304\n"), 0o600))
+
+	lock, err := acquireLockFile(path, true)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, lock.release()) }()
+
+	assert.Equal(t, 4194304, lock.stalePID)
+}
+
+func TestAcquireLockFile_InstanceLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.lock")
+
+	lock, err := acquireLockFile(path, false)
+	require.NoError(t, err)
+
+	_, err = acquireLockFile(path, false)
+	assert.IsType(t, &lockedError{}, err)
+
+	require.NoError(t, lock.release())
+	assert.FileExists(t, path)
+}
+
+func TestRun_InstanceLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.lock")
+
+	lock, err := acquireLockFile(path, false)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, lock.release()) }()
+
+	var called bool
+	fn := func(context.Context, *zap.Logger, *axiom.Client) error {
+		called = true
+		return nil
+	}
+
+	res := RunE("test", fn,
+		withTestAxiomOptions(),
+		WithInstanceLock(path),
+	)
+	assert.Equal(t, ExitLocked, res.ExitCode)
+	assert.False(t, called)
+}
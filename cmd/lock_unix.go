@@ -0,0 +1,117 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// maxLockAttempts is the number of times acquiring a lock file is retried if
+// the file is replaced by another process while the lock is being acquired.
+const maxLockAttempts = 3
+
+// acquireLockFile opens the file at the given path and locks it exclusively. If
+// the file is locked by another process, a `*lockedError` is returned.
+func acquireLockFile(path string, writePID bool) (*lockFile, error) {
+	for i := 0; i < maxLockAttempts; i++ {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			pid := readPID(f)
+			_ = f.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil, &lockedError{path: path, pid: pid}
+			}
+			return nil, err
+		}
+
+		// A previous owner might have removed the file after we opened it but
+		// before we acquired the lock. In that case, we hold a lock on a file
+		// nobody else will ever see, so try again.
+		same, err := isSameFile(f, path)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		} else if !same {
+			_ = f.Close()
+			continue
+		}
+
+		lf := &lockFile{
+			f:        f,
+			path:     path,
+			writePID: writePID,
+			stalePID: readPID(f),
+		}
+
+		if writePID {
+			if err = lf.writeOwnPID(); err != nil {
+				_ = lf.release()
+				return nil, err
+			}
+		}
+
+		return lf, nil
+	}
+
+	return nil, &lockedError{path: path}
+}
+
+// release removes the file, if it is a PID file, and releases the lock.
+func (lf *lockFile) release() error {
+	var removeErr error
+	if lf.writePID {
+		// Remove the file while still holding the lock so no other instance
+		// can acquire it in between.
+		if err := os.Remove(lf.path); err != nil && !os.IsNotExist(err) {
+			removeErr = err
+		}
+	}
+
+	unlockErr := syscall.Flock(int(lf.f.Fd()), syscall.LOCK_UN)
+	closeErr := lf.f.Close()
+
+	switch {
+	case removeErr != nil:
+		return removeErr
+	case unlockErr != nil:
+		return unlockErr
+	}
+	return closeErr
+}
+
+// writeOwnPID replaces the content of the file with the process ID of the
+// running process.
+func (lf *lockFile) writeOwnPID() error {
+	if err := lf.f.Truncate(0); err != nil {
+		return err
+	} else if _, err = lf.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	} else if _, err = lf.f.WriteString(strconv.Itoa(os.Getpid()) + "\n"); err != nil {
+		return err
+	}
+	return lf.f.Sync()
+}
+
+// isSameFile reports whether the opened file is still the one referred to by
+// the given path.
+func isSameFile(f *os.File, path string) (bool, error) {
+	openInfo, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	pathInfo, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return os.SameFile(openInfo, pathInfo), nil
+}
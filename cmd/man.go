@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/axiomhq/pkg/version"
+)
+
+// roffEscaper escapes text for use in roff documents.
+var roffEscaper = strings.NewReplacer(`\`, `\e`, `-`, `\-`)
+
+// roffText escapes the text and makes sure lines starting with a control
+// character are not interpreted as requests.
+func roffText(s string) string {
+	s = roffEscaper.Replace(s)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+// writeManPage writes the man page of the application in roff format.
+func writeManPage(w io.Writer, spec commandSpec) error {
+	bw := bufio.NewWriter(w)
+	p := func(format string, args ...interface{}) {
+		_, _ = fmt.Fprintf(bw, format+"\n", args...)
+	}
+
+	// The source field names the release, unless it is unknown, which is
+	// reported as "-" by builds that don't set it.
+	source := spec.name
+	if release := version.Release(); release != "" && release != "-" {
+		source += " " + release
+	}
+	p(`.TH "%s" "1" "" "%s" "User Commands"`, roffText(strings.ToUpper(spec.name)), roffText(source))
+
+	p(".SH NAME")
+	if spec.description != "" {
+		p(`%s \- %s`, roffText(spec.name), roffText(spec.description))
+	} else {
+		p("%s", roffText(spec.name))
+	}
+
+	p(".SH SYNOPSIS")
+	p(".B %s", roffText(spec.name))
+	if len(spec.flags) > 0 {
+		p(".RI [ options ]")
+	}
+	if len(spec.subcommands) > 0 {
+		p(".I subcommand")
+	}
+
+	if len(spec.flags) > 0 {
+		p(".SH OPTIONS")
+		for _, f := range spec.flags {
+			p(".TP")
+			if f.value != "" {
+				p(`.BI \-%s " %s"`, roffText(f.name), roffText(f.value))
+			} else {
+				p(`.B \-%s`, roffText(f.name))
+			}
+			usage := f.usage
+			if f.def != "" && (f.value != "" || f.def != "false") {
+				usage = strings.TrimSpace(fmt.Sprintf("%s Defaults to %q.", usage, f.def))
+			}
+			p("%s", roffText(usage))
+		}
+	}
+
+	if len(spec.subcommands) > 0 {
+		p(".SH SUBCOMMANDS")
+		for _, sc := range spec.subcommands {
+			p(".TP")
+			p(".B %s", roffText(sc.Name))
+			p("%s", roffText(sc.Usage))
+		}
+	}
+
+	if len(spec.envVars) > 0 {
+		p(".SH ENVIRONMENT")
+		for _, env := range spec.envVars {
+			p(".TP")
+			p(".B %s", roffText(env.name))
+			usage := env.usage
+			if env.required {
+				usage = strings.TrimSpace(usage + " Required.")
+			}
+			p("%s", roffText(usage))
+		}
+	}
+
+	if len(spec.signals) > 0 {
+		p(".SH SIGNALS")
+		for _, sig := range spec.signals {
+			p(".TP")
+			p(".B %s", roffText(sig.name))
+			p("%s", roffText(sig.usage))
+		}
+	}
+
+	p(".SH EXIT STATUS")
+	for _, ec := range spec.exitCodes {
+		p(".TP")
+		p(".B %d", ec.code)
+		p("%s", roffText(ec.usage))
+	}
+
+	return bw.Flush()
+}
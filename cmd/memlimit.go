@@ -0,0 +1,24 @@
+//go:build go1.19
+
+package cmd
+
+import (
+	"math"
+	"runtime/debug"
+)
+
+// setMemoryLimit sets the soft memory limit of the Go runtime. It reports
+// whether the limit was applied.
+func setMemoryLimit(limit int64) bool {
+	debug.SetMemoryLimit(limit)
+	return true
+}
+
+// memoryLimit returns the soft memory limit of the Go runtime. It returns zero
+// if no limit is set.
+func memoryLimit() int64 {
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		return limit
+	}
+	return 0
+}
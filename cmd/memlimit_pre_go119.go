@@ -0,0 +1,13 @@
+//go:build !go1.19
+
+package cmd
+
+// setMemoryLimit is not supported before Go 1.19.
+func setMemoryLimit(int64) bool {
+	return false
+}
+
+// memoryLimit is not supported before Go 1.19.
+func memoryLimit() int64 {
+	return 0
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// notifier sends state notifications to the service manager using the systemd
+// notification protocol. All of its methods are no-ops if the application is
+// not run as a unit with notification support.
+type notifier struct {
+	mu   sync.Mutex
+	conn *net.UnixConn
+
+	// watchdogInterval is the interval at which the service manager expects
+	// keep-alive pings. It is zero if the watchdog is disabled.
+	watchdogInterval time.Duration
+
+	readyOnce    sync.Once
+	stoppingOnce sync.Once
+}
+
+// newNotifier creates a new notifier from the "NOTIFY_SOCKET", "WATCHDOG_USEC"
+// and "WATCHDOG_PID" environment variables, looked up by the resolver. If the
+// service manager can't be reached, a disabled notifier is returned alongside
+// the error.
+func newNotifier(env envResolver) (*notifier, error) {
+	n := new(notifier)
+
+	addr := env.getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return n, nil
+	}
+
+	// Addresses starting with "@" refer to the abstract socket namespace which
+	// is handled transparently by the net package.
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return n, err
+	}
+	n.conn = conn
+
+	// The watchdog is only meant for us if no PID is given or the given PID
+	// matches our own.
+	if pid := env.getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return n, nil
+	}
+	if usec, parseErr := strconv.ParseInt(env.getenv("WATCHDOG_USEC"), 10, 64); parseErr == nil && usec > 0 {
+		n.watchdogInterval = time.Duration(usec) * time.Microsecond
+	}
+
+	return n, nil
+}
+
+// enabled reports whether the notifier is connected to a service manager.
+func (n *notifier) enabled() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.conn != nil
+}
+
+// notify sends the given state assignments to the service manager.
+func (n *notifier) notify(state ...string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn == nil {
+		return nil
+	}
+	_, err := n.conn.Write([]byte(strings.Join(state, "\n")))
+	return err
+}
+
+// ready tells the service manager that startup is finished. Only the first
+// call has an effect.
+func (n *notifier) ready() (err error) {
+	n.readyOnce.Do(func() {
+		err = n.notify("READY=1", "STATUS=started")
+	})
+	return err
+}
+
+// stopping tells the service manager that the application is shutting down.
+// Only the first call has an effect.
+func (n *notifier) stopping() (err error) {
+	n.stoppingOnce.Do(func() {
+		err = n.notify("STOPPING=1", "STATUS=stopping")
+	})
+	return err
+}
+
+// status sends a free-form status message to the service manager.
+func (n *notifier) status(msg string) error {
+	return n.notify("STATUS=" + msg)
+}
+
+// watchdog sends keep-alive pings to the service manager at half the interval
+// it requested, as long as the given health check passes. It blocks until the
+// context is marked done.
+func (n *notifier) watchdog(ctx context.Context, logger *zap.Logger, healthCheck func(context.Context) error) {
+	if n.watchdogInterval == 0 || !n.enabled() {
+		return
+	}
+
+	t := time.NewTicker(n.watchdogInterval / 2)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		if healthCheck != nil {
+			if err := healthCheck(ctx); err != nil {
+				logger.Warn("health check failed, skipping watchdog ping", zap.Error(err))
+				continue
+			}
+		}
+
+		if err := n.notify("WATCHDOG=1"); err != nil {
+			logger.Error("send watchdog ping", zap.Error(err))
+		}
+	}
+}
+
+// close the connection to the service manager. Subsequent notifications are
+// discarded.
+func (n *notifier) close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn == nil {
+		return nil
+	}
+	err := n.conn.Close()
+	n.conn = nil
+	return err
+}
+
+// combineHealthChecks returns a health check which fails if any of the given
+// health checks fails. Nil health checks are ignored.
+func combineHealthChecks(healthChecks ...func(context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		for _, healthCheck := range healthChecks {
+			if healthCheck == nil {
+				continue
+			}
+			if err := healthCheck(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+type notifierKey struct{}
+
+// withNotifier returns a copy of the context that carries the notifier.
+func withNotifier(ctx context.Context, n *notifier) context.Context {
+	return context.WithValue(ctx, notifierKey{}, n)
+}
+
+// notifierFromContext returns the notifier carried by the context. A disabled
+// notifier is returned if the context doesn't carry one.
+func notifierFromContext(ctx context.Context) *notifier {
+	if n, ok := ctx.Value(notifierKey{}).(*notifier); ok {
+		return n
+	}
+	return new(notifier)
+}
+
+// Ready reports to the service manager that the application finished its
+// startup and is ready to serve. It must be called by the `RunFunc` when the
+// `WithManualReadiness()` option is used and has no effect otherwise. Only the
+// first call has an effect. The context must be the one passed to the
+// `RunFunc`.
+func Ready(ctx context.Context) error {
+	readinessFromContext(ctx).setReady()
+	return notifierFromContext(ctx).ready()
+}
+
+// Status reports a free-form status message to the service manager, e.g.
+// "processed 1000 events". The context must be the one passed to the
+// `RunFunc`.
+func Status(ctx context.Context, msg string) error {
+	return notifierFromContext(ctx).status(msg)
+}
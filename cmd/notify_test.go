@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// listenNotifySocket creates a unixgram socket, points "NOTIFY_SOCKET" to it
+// and returns a channel that receives all notifications sent to it.
+func listenNotifySocket(t *testing.T) <-chan string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	msgCh := make(chan string, 64)
+	go func() {
+		defer close(msgCh)
+		buf := make([]byte, 1024)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			msgCh <- string(buf[:n])
+		}
+	}()
+
+	return msgCh
+}
+
+// receive returns the next notification or fails the test after a timeout.
+func receive(t *testing.T, msgCh <-chan string) string {
+	t.Helper()
+
+	select {
+	case msg := <-msgCh:
+		return msg
+	case <-time.After(time.Second * 5):
+		require.FailNow(t, "timeout waiting for notification")
+	}
+	return ""
+}
+
+func TestRun_Notify(t *testing.T) {
+	msgCh := listenNotifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "50000")
+
+	fn := func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		assert.Equal(t, "READY=1\nSTATUS=started", receive(t, msgCh))
+
+		require.NoError(t, Status(ctx, "working"))
+		assert.Equal(t, "STATUS=working", receive(t, msgCh))
+
+		assert.Equal(t, "WATCHDOG=1", receive(t, msgCh))
+		assert.Equal(t, "WATCHDOG=1", receive(t, msgCh))
+
+		return nil
+	}
+
+	res := RunE("test", fn, withTestAxiomOptions())
+	assert.Equal(t, ExitOK, res.ExitCode)
+
+	// Watchdog pings might still arrive before shutdown is reported.
+	msg := receive(t, msgCh)
+	for msg == "WATCHDOG=1" {
+		msg = receive(t, msgCh)
+	}
+	assert.Equal(t, "STOPPING=1\nSTATUS=stopping", msg)
+}
+
+func TestRun_NotifyManualReadinessAndHealthCheck(t *testing.T) {
+	msgCh := listenNotifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "20000")
+
+	fn := func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		// Unhealthy applications must not send watchdog pings.
+		select {
+		case msg := <-msgCh:
+			assert.Failf(t, "unexpected notification", "got %q", msg)
+		case <-time.After(time.Millisecond * 100):
+		}
+
+		require.NoError(t, Ready(ctx))
+		assert.Equal(t, "READY=1\nSTATUS=started", receive(t, msgCh))
+
+		return nil
+	}
+
+	res := RunE("test", fn,
+		withTestAxiomOptions(),
+		WithManualReadiness(),
+		WithHealthCheck(func(context.Context) error {
+			return context.DeadlineExceeded
+		}),
+	)
+	assert.Equal(t, ExitOK, res.ExitCode)
+	assert.Equal(t, "STOPPING=1\nSTATUS=stopping", receive(t, msgCh))
+}
+
+func TestRun_NotifyUnreachable(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", filepath.Join(t.TempDir(), "missing.sock"))
+
+	res := RunE("test", func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		assert.NoError(t, Status(ctx, "working"))
+		return nil
+	}, withTestAxiomOptions())
+	assert.Equal(t, ExitOK, res.ExitCode)
+}
+
+func TestRun_NotifyEnvLookup(t *testing.T) {
+	msgCh := listenNotifySocket(t)
+
+	// The service manager variables are looked up using the function only.
+	path := os.Getenv("NOTIFY_SOCKET")
+	t.Setenv("NOTIFY_SOCKET", "")
+	lookupEnv := func(name string) (string, bool) {
+		if name == "NOTIFY_SOCKET" {
+			return path, true
+		}
+		return "", false
+	}
+
+	res := RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error {
+		assert.Equal(t, "READY=1\nSTATUS=started", receive(t, msgCh))
+		return nil
+	}, withTestAxiomOptions(), WithEnvLookup(lookupEnv))
+	assert.Equal(t, ExitOK, res.ExitCode)
+}
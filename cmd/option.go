@@ -1,10 +1,19 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/axiomhq/axiom-go/axiom"
 	"go.uber.org/zap"
+
+	"github.com/axiomhq/pkg/flags"
+	"github.com/axiomhq/pkg/scheduler"
 )
 
 // An Option modifies the behaviour of the `Run()` function.
@@ -57,3 +66,479 @@ func WithValidateAxiomCredentials() Option {
 		return nil
 	}
 }
+
+// WithPIDFile writes the process ID to the file at the given path and locks it
+// exclusively for the lifetime of the application. The file is removed on
+// exit. If the file is locked by another instance, the application fails to
+// start. A file left behind by an instance that is no longer running is
+// considered stale and taken over.
+func WithPIDFile(path string) Option {
+	return func(c *config) error {
+		c.pidFile = path
+		return nil
+	}
+}
+
+// WithInstanceLock locks the file at the given path exclusively for the
+// lifetime of the application, making sure only a single instance is running
+// at a time. If the file is locked by another instance, the application fails
+// to start.
+func WithInstanceLock(path string) Option {
+	return func(c *config) error {
+		c.instanceLock = path
+		return nil
+	}
+}
+
+// WithManualReadiness disables reporting readiness to the service manager when
+// the application has started. Instead, the `RunFunc` must call `Ready()` once
+// it is ready to serve.
+func WithManualReadiness() Option {
+	return func(c *config) error {
+		c.manualReadiness = true
+		return nil
+	}
+}
+
+// WithHealthCheck sets the function used to check the health of the
+// application. If the application is run by a service manager with the
+// watchdog enabled, keep-alive pings are only sent while the health check
+// passes.
+func WithHealthCheck(fn func(context.Context) error) Option {
+	return func(c *config) error {
+		c.healthCheck = fn
+		return nil
+	}
+}
+
+// WithLivenessWatchdog enables the liveness watchdog which monitors loops that
+// report their progress using `Heartbeat()`. A loop that doesn't report a
+// heartbeat within the given deadline is considered stuck. In that case, a
+// goroutine dump is logged and the watchdog reacts according to the given
+// policy. Deadlines of individual loops can be set using the
+// `WithHeartbeatDeadline()` option.
+func WithLivenessWatchdog(deadline time.Duration, policy LivenessPolicy) Option {
+	return func(c *config) error {
+		if deadline <= 0 {
+			return errors.New("liveness deadline must be positive")
+		}
+		c.livenessDeadline = deadline
+		c.livenessPolicy = policy
+		return nil
+	}
+}
+
+// WithHeartbeatDeadline sets the deadline of the named loop, overwriting the
+// deadline set by the `WithLivenessWatchdog()` option. In contrast to other
+// loops, the named loop is monitored right from the start and not only after
+// its first heartbeat. It has no effect if the liveness watchdog isn't
+// enabled.
+func WithHeartbeatDeadline(name string, deadline time.Duration) Option {
+	return func(c *config) error {
+		if deadline <= 0 {
+			return errors.New("heartbeat deadline must be positive")
+		}
+		if c.heartbeatDeadlines == nil {
+			c.heartbeatDeadlines = make(map[string]time.Duration)
+		}
+		c.heartbeatDeadlines[name] = deadline
+		return nil
+	}
+}
+
+// WithContainerTuning configures the Go runtime according to the CPU and memory
+// limits of the cgroup the application is running in. `GOMAXPROCS` is set to
+// the CPU quota, rounded up, and the soft memory limit is set to the memory
+// limit minus the given headroom ratio, e.g. 0.1 to leave 10% of the memory for
+// allocations not managed by the Go runtime. Values explicitly configured
+// using the "GOMAXPROCS" and "GOMEMLIMIT" environment variables take
+// precedence. Setting the memory limit requires Go 1.19 or later.
+func WithContainerTuning(memoryHeadroom float64) Option {
+	return func(c *config) error {
+		if memoryHeadroom < 0 || memoryHeadroom >= 1 {
+			return errors.New("memory headroom must be in the range [0, 1)")
+		}
+		c.containerTuning = true
+		c.memoryHeadroom = memoryHeadroom
+		return nil
+	}
+}
+
+// WithRuntimeStats periodically reports runtime statistics like memory usage,
+// number of goroutines, garbage collector pauses, scheduler latencies and open
+// file descriptors at the given interval. They are logged and, if a dataset is
+// set using the `WithRuntimeStatsDataset()` option, ingested into Axiom.
+func WithRuntimeStats(interval time.Duration) Option {
+	return func(c *config) error {
+		if interval <= 0 {
+			return errors.New("runtime stats interval must be positive")
+		}
+		c.runtimeStatsInterval = interval
+		return nil
+	}
+}
+
+// WithRuntimeStatsDataset sets the dataset the runtime statistics reported by
+// the `WithRuntimeStats()` option are ingested into.
+func WithRuntimeStatsDataset(dataset string) Option {
+	return func(c *config) error {
+		c.runtimeStatsDataset = dataset
+		return nil
+	}
+}
+
+// WithJobs adds jobs which run periodically according to their schedules for
+// the lifetime of the application. The context passed to the jobs is marked
+// done on shutdown and the application waits for runs in progress to return.
+// Every run is logged with its duration and error. Applications that only run
+// jobs must still block in their `RunFunc` until the context is marked done.
+func WithJobs(jobs ...scheduler.Job) Option {
+	return func(c *config) error {
+		c.jobs = append(c.jobs, jobs...)
+		return nil
+	}
+}
+
+// WithBatchMode runs the application as a batch job which runs to completion.
+// In contrast to regular applications, the `RunFunc` doesn't block until the
+// context is marked done but returns as soon as its work is done. Returning
+// nil means success. The progress of the job can be tracked using
+// `Progress()`. It is logged at the given interval, unless the interval is
+// zero. Once the job finished, a summary is logged and, if a dataset is set
+// using the `WithBatchSummaryDataset()` option, ingested into Axiom.
+func WithBatchMode(progressInterval time.Duration) Option {
+	return func(c *config) error {
+		if progressInterval < 0 {
+			return errors.New("batch progress interval must not be negative")
+		}
+		c.batchMode = true
+		c.batchProgressInterval = progressInterval
+		return nil
+	}
+}
+
+// WithBatchSummaryDataset sets the dataset the summary of a batch job is
+// ingested into. It has no effect if the application is not run in batch mode
+// using the `WithBatchMode()` option.
+func WithBatchSummaryDataset(dataset string) Option {
+	return func(c *config) error {
+		c.batchSummaryDataset = dataset
+		return nil
+	}
+}
+
+// WithAxiomProfiles creates additional Axiom clients for the named profiles.
+// They are available to the `RunFunc` through the registry returned by
+// `Clients()`. A profile is configured by the "AXIOM_<NAME>_URL",
+// "AXIOM_<NAME>_TOKEN" and "AXIOM_<NAME>_ORG_ID" environment variables, with
+// the uppercased name, e.g. "AXIOM_SOURCE_TOKEN" for the "source" profile. If
+// the token is not set, the deployment with the same name is read from the
+// Axiom CLI configuration file. The credentials of all profiles are validated
+// if the `WithValidateAxiomCredentials()` option is set.
+func WithAxiomProfiles(names ...string) Option {
+	return func(c *config) error {
+		c.axiomProfiles = names
+		return nil
+	}
+}
+
+// WithAxiomConfigFile sets the path of the Axiom CLI configuration file which
+// profiles configured by the `WithAxiomProfiles()` option are read from. It
+// defaults to "~/.axiom.toml".
+func WithAxiomConfigFile(path string) Option {
+	return func(c *config) error {
+		c.axiomConfigFile = path
+		return nil
+	}
+}
+
+// WithLifecycleEvents ingests lifecycle events of the application ("starting",
+// "started", "stopping", "stopped" and "crashed") into the given dataset. The
+// events carry the application name, version information, hostname, PID,
+// uptime and, once stopped, the exit code and shutdown reason. Events are
+// delivered asynchronously and outstanding ones are flushed on shutdown for at
+// most the given timeout, which defaults to five seconds if zero. Events
+// reported before the Axiom client is created are dropped if creating it
+// fails.
+func WithLifecycleEvents(dataset string, timeout time.Duration) Option {
+	return func(c *config) error {
+		if dataset == "" {
+			return errors.New("lifecycle events dataset must not be empty")
+		} else if timeout < 0 {
+			return errors.New("lifecycle events timeout must not be negative")
+		}
+		c.lifecycleDataset = dataset
+		c.lifecycleTimeout = timeout
+		return nil
+	}
+}
+
+// WithDrainDelay enables a drain phase before the application shuts down. When
+// an exit signal arrives, the application is no longer reported as ready by
+// `IsReady()` and `ReadinessHandler()`, and only after the given delay the
+// context passed to the `RunFunc` is marked done. This gives load balancers
+// time to stop routing traffic to the application. A second exit signal ends
+// the drain phase immediately.
+func WithDrainDelay(delay time.Duration) Option {
+	return func(c *config) error {
+		if delay < 0 {
+			return errors.New("drain delay must not be negative")
+		}
+		c.drainDelay = delay
+		return nil
+	}
+}
+
+// WithFlags loads the given flag set on startup and reloads it whenever its
+// flag file changes, until the application stops. Invalid flag values on
+// startup are treated as a configuration error. Changes are logged.
+func WithFlags(set *flags.Set) Option {
+	return func(c *config) error {
+		if set == nil {
+			return errors.New("flag set must not be nil")
+		}
+		c.flags = set
+		return nil
+	}
+}
+
+// WithSupervisor restarts the `RunFunc` in-process when it fails, instead of
+// exiting the application. Restarts are delayed by an exponential backoff and
+// logged with the error that caused them. The `RunFunc` is not restarted if it
+// returns without an error, the context passed to it is marked done or the
+// error is categorized as `errors.Permanent` or `errors.InvalidInput`. Panics
+// are recovered and restarted from as well.
+func WithSupervisor(policy SupervisorPolicy) Option {
+	return func(c *config) error {
+		if err := policy.validate(); err != nil {
+			return err
+		}
+		c.supervisor = &policy
+		return nil
+	}
+}
+
+// WithHooks adds hooks which are called at certain points of the application
+// lifecycle. Hooks are called in the order they are added, after the globally
+// registered ones. `AfterRun` hooks are called in reverse order.
+func WithHooks(hooks ...Hooks) Option {
+	return func(c *config) error {
+		c.hooks = append(c.hooks, hooks...)
+		return nil
+	}
+}
+
+// WithProfileDumps writes heap, goroutine, mutex, block and a 30 second CPU
+// profile into a new, timestamped directory inside the given one when SIGUSR2
+// arrives. The directory defaults to the temporary directory if empty. Mutex
+// and block profiling is enabled with low sampling rates. Only supported on
+// Unix platforms. SIGUSR2 must not be an exit signal.
+func WithProfileDumps(dir string) Option {
+	return func(c *config) error {
+		c.profileDumps = true
+		c.profileDumpDir = dir
+		return nil
+	}
+}
+
+// WithSignalHandler calls the given function whenever the signal arrives. The
+// context passed to it is marked done when the application stops. Handlers of
+// all signals are called serially, in the order they are registered, and
+// panics are recovered and logged. The signal must not be an exit signal, see
+// `WithExitSignals()`. A handler registered for SIGQUIT, which requires leaving
+// it out of the exit signals, replaces the goroutine dump.
+func WithSignalHandler(sig os.Signal, fn func(context.Context)) Option {
+	return func(c *config) error {
+		if sig == nil || fn == nil {
+			return errors.New("signal and handler must not be nil")
+		}
+		c.signalHandlers = append(c.signalHandlers, signalHandler{sig, fn})
+		return nil
+	}
+}
+
+// WithDescription sets a short, one line description of the application. It is
+// used in the generated man page.
+func WithDescription(description string) Option {
+	return func(c *config) error {
+		c.description = description
+		return nil
+	}
+}
+
+// WithHiddenCommands enables the hidden commands which generate documentation
+// for the application instead of running it: "completion bash|zsh|fish" writes
+// a shell completion script and "man" writes the man page. They are run when
+// their name is the first argument. Applications which take positional
+// arguments should only enable them if those can't collide.
+func WithHiddenCommands() Option {
+	return func(c *config) error {
+		c.hiddenCommands = true
+		return nil
+	}
+}
+
+// WithCommandLine declares the command line flags of the application. The
+// arguments are parsed with the flag set before the application starts and
+// `Invocation.Args` holds the remaining positional arguments. Invalid arguments
+// make the application exit with `ExitConfig`, asking for help with `ExitOK`.
+// The flags are completed by the generated shell completion scripts and
+// documented in the man page.
+func WithCommandLine(set *flag.FlagSet) Option {
+	return func(c *config) error {
+		if set == nil {
+			return errors.New("command line flag set must not be nil")
+		}
+		c.commandLine = set
+		return nil
+	}
+}
+
+// WithSubcommands declares the subcommands of the application. Dispatching
+// them is up to the `RunFunc`, which finds the name of the subcommand as the
+// first positional argument. They are completed by the generated shell
+// completion scripts and documented in the man page.
+func WithSubcommands(subcommands ...Subcommand) Option {
+	return func(c *config) error {
+		seen := make(map[string]struct{}, len(c.subcommands))
+		for _, sc := range c.subcommands {
+			seen[sc.Name] = struct{}{}
+		}
+		for _, sc := range subcommands {
+			if !subcommandName.MatchString(sc.Name) {
+				return fmt.Errorf("invalid subcommand name %q", sc.Name)
+			} else if _, ok := seen[sc.Name]; ok {
+				return fmt.Errorf("subcommand %q declared twice", sc.Name)
+			}
+			seen[sc.Name] = struct{}{}
+			c.subcommands = append(c.subcommands, sc)
+		}
+		return nil
+	}
+}
+
+// WithAppEnvPrefix scopes environment variables to the application by
+// prefixing them with its name, e.g. "MY_APP_" for "my-app". The prefixed
+// variable takes precedence over the unprefixed one for the required
+// environment variables, the Axiom client and profile configuration, the
+// variables of the flags, "DEBUG" and `Getenv()`. Explicitly passed Axiom
+// options still take precedence. The startup log shows the values and which
+// variable each of them was found in, with the values of secrets masked.
+func WithAppEnvPrefix() Option {
+	return func(c *config) error {
+		c.appEnvPrefix = true
+		return nil
+	}
+}
+
+// WithSlowPhaseThreshold sets the duration after which a startup phase is
+// logged as slow. It defaults to five seconds. A zero threshold disables the
+// warning. The timings of all phases are logged when the application started
+// and reported in the `Phases` field of the result returned by `RunE()`.
+func WithSlowPhaseThreshold(threshold time.Duration) Option {
+	return func(c *config) error {
+		if threshold < 0 {
+			return errors.New("slow phase threshold must not be negative")
+		}
+		c.slowPhaseThreshold = threshold
+		return nil
+	}
+}
+
+// WithRlimits sets the soft limits of the given process resources on startup,
+// e.g. raises the number of open file descriptors to the hard limit using
+// `Rlimit{RlimitNofile, RlimitMax}`. Limits above the hard limit are lowered
+// to it. On macOS, the number of open file descriptors is also capped at the
+// maximum per process, as the kernel rejects higher limits even if the hard
+// limit is unlimited. The effective limits are logged. Failing to set a limit is logged but
+// doesn't prevent the application from starting. Resource limits are only
+// supported on Linux and macOS.
+func WithRlimits(limits ...Rlimit) Option {
+	return func(c *config) error {
+		for _, l := range limits {
+			if l.Resource > RlimitAS {
+				return fmt.Errorf("unknown resource %s", l.Resource)
+			}
+		}
+		c.rlimits = append(c.rlimits, limits...)
+		return nil
+	}
+}
+
+// WithHardening restricts the process once the application started. It sets
+// "no_new_privs", so neither the application nor its child processes can gain
+// privileges, e.g. through setuid binaries. If supported by the kernel,
+// filesystem access is restricted to the paths of the policy using Landlock.
+// The paths enabled features of the application need are added to it, see
+// `HardeningPolicy`. The files the Go resolver and TLS implementation read after startup, "/etc/resolv.conf",
+// "/etc/hosts", "/etc/nsswitch.conf", "/etc/services", "/etc/ssl" and
+// "/etc/pki", are always readable, if they exist. Certificates configured by
+// "SSL_CERT_FILE" or "SSL_CERT_DIR" elsewhere must be added to the policy. The
+// applied policy is logged. If the kernel doesn't support Landlock, a warning
+// is logged and only "no_new_privs" is set.
+//
+// Hardening is only supported on Linux and requires a binary built without
+// cgo, i.e. with "CGO_ENABLED=0", as the restrictions must be applied to all
+// threads of the process. Otherwise, a warning is logged and the application
+// runs without restrictions, unless the policy is required, which fails the
+// application instead.
+func WithHardening(policy HardeningPolicy) Option {
+	return func(c *config) error {
+		c.hardening = &HardeningPolicy{
+			ReadPaths:  append([]string(nil), policy.ReadPaths...),
+			WritePaths: append([]string(nil), policy.WritePaths...),
+			Required:   policy.Required,
+		}
+		for _, path := range append(c.hardening.ReadPaths, c.hardening.WritePaths...) {
+			if path == "" {
+				return errors.New("hardening policy paths must not be empty")
+			}
+		}
+		return nil
+	}
+}
+
+// WithArgs sets the command line arguments of the application. They default to
+// the arguments the process was started with, without the program name, and
+// can be read using `InvocationFrom()`. If the application declares its command
+// line flags using `WithCommandLine()`, they are parsed from the arguments.
+func WithArgs(args ...string) Option {
+	return func(c *config) error {
+		c.invocation.Args = args
+		return nil
+	}
+}
+
+// WithIO sets the standard streams of the application, which can be read using
+// `InvocationFrom()`. Streams that are nil default to the ones of the process.
+func WithIO(stdin io.Reader, stdout, stderr io.Writer) Option {
+	return func(c *config) error {
+		if stdin != nil {
+			c.invocation.Stdin = stdin
+		}
+		if stdout != nil {
+			c.invocation.Stdout = stdout
+		}
+		if stderr != nil {
+			c.invocation.Stderr = stderr
+		}
+		return nil
+	}
+}
+
+// WithEnvLookup sets the function environment variables are looked up with
+// instead of the environment of the process. It is used for the required
+// environment variables, the Axiom client and profile configuration, the
+// variables of the flags, the variables of the service manager, "DEBUG" and
+// `Getenv()`. The Axiom client doesn't read the environment of the process
+// then. Only variables read by the Go runtime, like "GOMAXPROCS", are still
+// taken from the environment of the process.
+func WithEnvLookup(fn func(string) (string, bool)) Option {
+	return func(c *config) error {
+		if fn == nil {
+			return errors.New("environment lookup function must not be nil")
+		}
+		c.invocation.env.lookupEnv = fn
+		return nil
+	}
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// defaultSlowPhaseThreshold is the default duration after which a startup
+// phase is considered slow.
+const defaultSlowPhaseThreshold = time.Second * 5
+
+// Phase is the timing of a phase of the application startup.
+type Phase struct {
+	// Name of the phase, e.g. "logger" or "axiom_client".
+	Name string
+	// Duration of the phase.
+	Duration time.Duration
+}
+
+// Phases are the timings of the startup phases, in the order they ran.
+type Phases []Phase
+
+// MarshalLogObject implements `zapcore.ObjectMarshaler`.
+func (p Phases) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, phase := range p {
+		enc.AddDuration(phase.Name, phase.Duration)
+	}
+	return nil
+}
+
+// phaseTimer times consecutive startup phases. Phases that take longer than
+// the threshold are logged as slow, as soon as a logger is available.
+type phaseTimer struct {
+	threshold time.Duration
+	logger    *zap.Logger
+
+	current string
+	start   time.Time
+	phases  Phases
+}
+
+// newPhaseTimer creates a new phase timer. A zero threshold disables slow
+// phase warnings.
+func newPhaseTimer(threshold time.Duration) *phaseTimer {
+	return &phaseTimer{threshold: threshold}
+}
+
+// begin ends the current phase, if any, and starts the named one at the given
+// time.
+func (t *phaseTimer) begin(name string, start time.Time) {
+	t.end()
+	t.current, t.start = name, start
+}
+
+// end the current phase, if any.
+func (t *phaseTimer) end() {
+	if t.current == "" {
+		return
+	}
+
+	phase := Phase{Name: t.current, Duration: time.Since(t.start)}
+	t.current = ""
+	t.phases = append(t.phases, phase)
+
+	if t.logger != nil {
+		t.warnIfSlow(phase)
+	}
+}
+
+// setLogger sets the logger slow phases are logged with. Slow phases that
+// ended before it was set are logged immediately.
+func (t *phaseTimer) setLogger(logger *zap.Logger) {
+	t.logger = logger
+	for _, phase := range t.phases {
+		t.warnIfSlow(phase)
+	}
+}
+
+func (t *phaseTimer) warnIfSlow(phase Phase) {
+	if t.threshold > 0 && phase.Duration > t.threshold {
+		t.logger.Warn("slow startup phase",
+			zap.String("phase", phase.Name),
+			zap.Duration("duration", phase.Duration),
+			zap.Duration("threshold", t.threshold),
+		)
+	}
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRun_Phases(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	res := RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error {
+		return nil
+	},
+		withTestAxiomOptions(),
+		WithLoggerOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(c, core)
+		})),
+		WithSlowPhaseThreshold(time.Millisecond*10),
+		WithHooks(Hooks{
+			BeforeRun: func(context.Context, *zap.Logger, *axiom.Client) error {
+				time.Sleep(time.Millisecond * 20)
+				return nil
+			},
+		}),
+	)
+	require.Equal(t, ExitOK, res.ExitCode)
+
+	names := make([]string, 0, len(res.Phases))
+	var total time.Duration
+	for _, phase := range res.Phases {
+		names = append(names, phase.Name)
+		total += phase.Duration
+	}
+	assert.Equal(t, []string{
+		"options", "logger", "starting", "env", "locks", "signals",
+		"axiom_client", "preflight", "hooks",
+	}, names)
+	assert.LessOrEqual(t, total, res.StartupDuration)
+
+	if entries := logs.FilterMessage("started").All(); assert.Len(t, entries, 1) {
+		phases := entries[0].ContextMap()["phases"]
+		assert.Len(t, phases, len(res.Phases))
+		assert.Contains(t, phases, "axiom_client")
+	}
+	if entries := logs.FilterMessage("slow startup phase").All(); assert.Len(t, entries, 1) {
+		assert.Equal(t, "hooks", entries[0].ContextMap()["phase"])
+	}
+}
+
+func TestRun_Phases_Failed(t *testing.T) {
+	res := RunE("test", nil, withTestAxiomOptions(), WithRequiredEnvVars("CMD_TEST_UNSET"))
+	require.Equal(t, ExitConfig, res.ExitCode)
+
+	if assert.Len(t, res.Phases, 4) {
+		assert.Equal(t, "env", res.Phases[3].Name)
+	}
+
+	// Invalid options fail the first phase.
+	res = RunE("test", nil, WithSlowPhaseThreshold(-1))
+	require.Equal(t, ExitConfig, res.ExitCode)
+	if assert.Len(t, res.Phases, 1) {
+		assert.Equal(t, "options", res.Phases[0].Name)
+	}
+}
+
+func TestRun_Phases_Optional(t *testing.T) {
+	res := RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error {
+		return nil
+	},
+		withTestAxiomOptions(),
+		WithCommandLine(flag.NewFlagSet("test", flag.ContinueOnError)),
+		WithArgs(),
+		WithRlimits(Rlimit{Resource: RlimitNofile, Value: RlimitMax}),
+	)
+	require.Equal(t, ExitOK, res.ExitCode)
+
+	names := make([]string, 0, len(res.Phases))
+	for _, phase := range res.Phases {
+		names = append(names, phase.Name)
+	}
+	assert.Equal(t, []string{"options", "command_line", "logger", "rlimits", "starting"}, names[:5])
+}
+
+func TestPhaseTimer_SlowBeforeLogger(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	timer := newPhaseTimer(time.Millisecond)
+	timer.begin("options", time.Now().Add(-time.Second))
+	timer.begin("logger", time.Now())
+	assert.Zero(t, logs.Len())
+
+	timer.setLogger(zap.New(core))
+	timer.end()
+
+	if entries := logs.FilterMessage("slow startup phase").All(); assert.NotEmpty(t, entries) {
+		assert.Equal(t, "options", entries[0].ContextMap()["phase"])
+	}
+	assert.Len(t, timer.phases, 2)
+}
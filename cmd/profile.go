@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/axiomhq/axiom-go/axiom"
+)
+
+// axiomConfigFileName is the name of the Axiom CLI configuration file in the
+// home directory of the user.
+const axiomConfigFileName = ".axiom.toml"
+
+// axiomProfile holds the configuration of a named Axiom deployment.
+type axiomProfile struct {
+	URL   string `toml:"url"`
+	Token string `toml:"token"`
+	OrgID string `toml:"org_id"`
+}
+
+// axiomConfigFile is the configuration file format of the Axiom CLI.
+type axiomConfigFile struct {
+	Deployments map[string]axiomProfile `toml:"deployments"`
+}
+
+// profileEnvPrefix returns the prefix of the environment variables configuring
+// the named profile, e.g. "AXIOM_SOURCE_" for the "source" profile.
+func profileEnvPrefix(name string) string {
+	return "AXIOM_" + strings.NewReplacer("-", "_", ".", "_").Replace(strings.ToUpper(name)) + "_"
+}
+
+// loadAxiomProfile loads the configuration of the named profile. Environment
+// variables, looked up by the resolver, take precedence over the Axiom CLI
+// configuration file. The file is ignored if it doesn't exist.
+func loadAxiomProfile(name, configFile string, env envResolver) (axiomProfile, error) {
+	prefix := profileEnvPrefix(name)
+	if token := env.getenv(prefix + "TOKEN"); token != "" {
+		return axiomProfile{
+			URL:   env.getenv(prefix + "URL"),
+			Token: token,
+			OrgID: env.getenv(prefix + "ORG_ID"),
+		}, nil
+	}
+
+	if configFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return axiomProfile{}, fmt.Errorf("axiom profile %q: no %sTOKEN set and %w", name, prefix, err)
+		}
+		configFile = filepath.Join(home, axiomConfigFileName)
+	}
+
+	var cfg axiomConfigFile
+	if _, err := toml.DecodeFile(configFile, &cfg); errors.Is(err, os.ErrNotExist) {
+		return axiomProfile{}, fmt.Errorf("axiom profile %q: no %sTOKEN set and no config file at %q", name, prefix, configFile)
+	} else if err != nil {
+		return axiomProfile{}, fmt.Errorf("axiom profile %q: read config file: %w", name, err)
+	}
+
+	profile, ok := cfg.Deployments[name]
+	if !ok || profile.Token == "" {
+		return axiomProfile{}, fmt.Errorf("axiom profile %q: no %sTOKEN set and no deployment in %q", name, prefix, configFile)
+	}
+	return profile, nil
+}
+
+// newClient creates a new Axiom client for the profile. It doesn't take any
+// configuration from the environment.
+func (p axiomProfile) newClient() (*axiom.Client, error) {
+	options := []axiom.Option{
+		axiom.SetNoEnv(),
+		axiom.SetAccessToken(p.Token),
+	}
+	if p.URL != "" {
+		options = append(options, axiom.SetURL(p.URL))
+	}
+	if p.OrgID != "" {
+		options = append(options, axiom.SetOrgID(p.OrgID))
+	}
+	return axiom.NewClient(options...)
+}
+
+// ClientRegistry holds the Axiom clients of all named profiles configured by
+// the `WithAxiomProfiles()` option.
+type ClientRegistry struct {
+	clients map[string]*axiom.Client
+}
+
+// newClientRegistry creates the clients for the named profiles.
+func newClientRegistry(names []string, configFile string, env envResolver) (*ClientRegistry, error) {
+	r := &ClientRegistry{
+		clients: make(map[string]*axiom.Client, len(names)),
+	}
+	for _, name := range names {
+		profile, err := loadAxiomProfile(name, configFile, env)
+		if err != nil {
+			return nil, err
+		}
+		if r.clients[name], err = profile.newClient(); err != nil {
+			return nil, fmt.Errorf("axiom profile %q: %w", name, err)
+		}
+	}
+	return r, nil
+}
+
+// Client returns the Axiom client of the named profile. An error is returned if
+// no such profile is configured.
+func (r *ClientRegistry) Client(name string) (*axiom.Client, error) {
+	if client, ok := r.clients[name]; ok {
+		return client, nil
+	}
+	return nil, fmt.Errorf("unknown axiom profile %q", name)
+}
+
+// Names returns the names of all configured profiles in lexical order.
+func (r *ClientRegistry) Names() []string {
+	names := make([]string, 0, len(r.clients))
+	for name := range r.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateCredentials validates the credentials of all clients.
+func (r *ClientRegistry) validateCredentials(ctx context.Context) error {
+	for _, name := range r.Names() {
+		if err := r.clients[name].ValidateCredentials(ctx); err != nil {
+			return fmt.Errorf("axiom profile %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+type clientRegistryKey struct{}
+
+// Clients returns the registry of the Axiom clients configured by the
+// `WithAxiomProfiles()` option. The context must be the one passed to the
+// `RunFunc`. If no profiles are configured, an empty registry is returned.
+func Clients(ctx context.Context) *ClientRegistry {
+	if r, ok := ctx.Value(clientRegistryKey{}).(*ClientRegistry); ok {
+		return r
+	}
+	return new(ClientRegistry)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testAxiomConfigFile = `
+active_deployment = "source"
+
+[deployments.source]
+url = "http://source.axiom.local"
+token = "xapt-source"
+
+[deployments.target]
+url = "https://cloud.axiom.co"
+token = "xapt-target"
+org_id = "target-org"
+`
+
+func TestLoadAxiomProfile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "axiom.toml")
+	require.NoError(t, os.WriteFile(configFile, []byte(testAxiomConfigFile), 0o600))
+
+	t.Setenv("AXIOM_SOURCE_URL", "http://env.axiom.local")
+	t.Setenv("AXIOM_SOURCE_TOKEN", "xapt-env")
+
+	profile, err := loadAxiomProfile("source", configFile, envResolver{})
+	require.NoError(t, err)
+	assert.Equal(t, axiomProfile{URL: "http://env.axiom.local", Token: "xapt-env"}, profile)
+
+	profile, err = loadAxiomProfile("target", configFile, envResolver{})
+	require.NoError(t, err)
+	assert.Equal(t, axiomProfile{URL: "https://cloud.axiom.co", Token: "xapt-target", OrgID: "target-org"}, profile)
+
+	_, err = loadAxiomProfile("unknown", configFile, envResolver{})
+	assert.EqualError(t, err, `axiom profile "unknown": no AXIOM_UNKNOWN_TOKEN set and no deployment in "`+configFile+`"`)
+
+	_, err = loadAxiomProfile("unknown", filepath.Join(t.TempDir(), "missing.toml"), envResolver{})
+	assert.Error(t, err)
+}
+
+func TestRun_AxiomProfiles(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "axiom.toml")
+	require.NoError(t, os.WriteFile(configFile, []byte(testAxiomConfigFile), 0o600))
+
+	fn := func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		assert.Equal(t, []string{"source", "target"}, Clients(ctx).Names())
+
+		client, err := Clients(ctx).Client("target")
+		assert.NoError(t, err)
+		assert.NotNil(t, client)
+
+		_, err = Clients(ctx).Client("unknown")
+		assert.EqualError(t, err, `unknown axiom profile "unknown"`)
+
+		return nil
+	}
+
+	res := RunE("test", fn,
+		withTestAxiomOptions(),
+		WithAxiomProfiles("source", "target"),
+		WithAxiomConfigFile(configFile),
+	)
+	assert.Equal(t, ExitOK, res.ExitCode)
+
+	res = RunE("test", fn,
+		withTestAxiomOptions(),
+		WithAxiomProfiles("unknown"),
+		WithAxiomConfigFile(configFile),
+	)
+	assert.Equal(t, ExitConfig, res.ExitCode)
+}
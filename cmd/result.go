@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"os"
+	"time"
+)
+
+// Result describes the outcome of an application run by `RunE()`.
+type Result struct {
+	// ExitCode is the code the application should exit with.
+	ExitCode ExitCode
+	// Err is the error that terminated the application. It is nil, if the
+	// application exited gracefully.
+	Err error
+	// Signal is the signal that caused the application to shut down. It is
+	// nil, if the application didn't shut down because of a signal.
+	Signal os.Signal
+	// Component is the name of the component that terminated the application
+	// run by `RunAllE()`. It is empty, if no component failed.
+	Component string
+
+	// StartTime is the time the application started bootstrapping.
+	StartTime time.Time
+	// StartupDuration is the time it took to bootstrap the application before
+	// calling the `RunFunc`. It is zero, if bootstrapping failed.
+	StartupDuration time.Duration
+	// Duration is the total run time of the application.
+	Duration time.Duration
+	// Phases are the timings of the startup phases that ran. If bootstrapping
+	// failed, the phase that failed is the last one.
+	Phases Phases
+}
+
+// withError returns a copy of the result with the given exit code and error.
+func (r Result) withError(code ExitCode, err error) Result {
+	r.ExitCode = code
+	r.Err = err
+	return r
+}
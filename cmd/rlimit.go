@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+
+	"go.uber.org/zap"
+)
+
+// RlimitResource is a process resource whose consumption can be limited.
+type RlimitResource uint8
+
+// All resources that can be limited using the `WithRlimits()` option.
+const (
+	// RlimitNofile limits the number of open file descriptors.
+	RlimitNofile RlimitResource = iota
+	// RlimitCore limits the size of core dumps in bytes. A limit of zero
+	// disables core dumps.
+	RlimitCore
+	// RlimitCPU limits the CPU time in seconds.
+	RlimitCPU
+	// RlimitData limits the size of the data segment in bytes.
+	RlimitData
+	// RlimitFsize limits the size of files created by the process in bytes.
+	RlimitFsize
+	// RlimitStack limits the size of the main thread stack in bytes.
+	RlimitStack
+	// RlimitAS limits the size of the virtual memory in bytes.
+	RlimitAS
+)
+
+// String returns the string representation of the resource.
+func (r RlimitResource) String() string {
+	switch r {
+	case RlimitNofile:
+		return "nofile"
+	case RlimitCore:
+		return "core"
+	case RlimitCPU:
+		return "cpu"
+	case RlimitData:
+		return "data"
+	case RlimitFsize:
+		return "fsize"
+	case RlimitStack:
+		return "stack"
+	case RlimitAS:
+		return "as"
+	}
+	return fmt.Sprintf("RlimitResource(%d)", r)
+}
+
+// RlimitMax sets a soft limit to the hard limit of the resource.
+const RlimitMax = math.MaxUint64
+
+// Rlimit is the soft limit of a resource. Limits above the hard limit are
+// lowered to the hard limit, as raising it requires privileges.
+type Rlimit struct {
+	// Resource to limit.
+	Resource RlimitResource
+	// Value of the soft limit. `RlimitMax` raises it to the hard limit.
+	Value uint64
+}
+
+// rlimitFields returns the effective soft and hard limit of the resource as
+// logger fields.
+func rlimitFields(resource RlimitResource, soft, hard uint64) []zap.Field {
+	return []zap.Field{
+		rlimitField("rlimit_"+resource.String(), soft),
+		rlimitField("rlimit_"+resource.String()+"_max", hard),
+	}
+}
+
+// rlimitField returns a logger field for the limit. Limits of math.MaxInt64
+// and above are logged as unlimited, as that is how "RLIM_INFINITY" is
+// represented on the supported platforms.
+func rlimitField(key string, limit uint64) zap.Field {
+	if limit >= math.MaxInt64 {
+		return zap.String(key, "unlimited")
+	}
+	return zap.Uint64(key, limit)
+}
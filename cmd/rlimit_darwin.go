@@ -0,0 +1,24 @@
+package cmd
+
+import "syscall"
+
+// openMax is the "OPEN_MAX" limit of the kernel. Raising the soft limit of
+// open files above it fails, even if the hard limit is unlimited.
+const openMax = 10240
+
+// maxSoftRlimit returns the highest soft limit of the resource the kernel
+// accepts, given its hard limit.
+func maxSoftRlimit(resource RlimitResource, hard uint64) uint64 {
+	if resource != RlimitNofile {
+		return hard
+	}
+
+	limit := uint64(openMax)
+	if n, err := syscall.SysctlUint32("kern.maxfilesperproc"); err == nil && n > 0 {
+		limit = uint64(n)
+	}
+	if limit > hard {
+		limit = hard
+	}
+	return limit
+}
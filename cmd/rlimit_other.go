@@ -0,0 +1,14 @@
+//go:build !(darwin || linux)
+
+package cmd
+
+import "errors"
+
+// errRlimitUnsupported is returned when resource limits are set on a platform
+// that doesn't support them.
+var errRlimitUnsupported = errors.New("resource limits are not supported on this platform")
+
+// setRlimit is not supported on this platform.
+func setRlimit(RlimitResource, uint64) (uint64, uint64, error) {
+	return 0, 0, errRlimitUnsupported
+}
@@ -0,0 +1,84 @@
+//go:build darwin || linux
+
+package cmd
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRun_Rlimits(t *testing.T) {
+	for _, id := range []int{syscall.RLIMIT_NOFILE, syscall.RLIMIT_CORE} {
+		var rl syscall.Rlimit
+		require.NoError(t, syscall.Getrlimit(id, &rl))
+		id := id
+		t.Cleanup(func() { _ = syscall.Setrlimit(id, &rl) })
+	}
+
+	// Lower the soft limit first, so there is something to raise.
+	var nofile syscall.Rlimit
+	require.NoError(t, syscall.Getrlimit(syscall.RLIMIT_NOFILE, &nofile))
+	require.NoError(t, syscall.Setrlimit(syscall.RLIMIT_NOFILE, &syscall.Rlimit{Cur: 64, Max: nofile.Max}))
+
+	core, logs := observer.New(zap.InfoLevel)
+
+	res := RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error {
+		return nil
+	},
+		withTestAxiomOptions(),
+		WithLoggerOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(c, core)
+		})),
+		WithRlimits(
+			Rlimit{RlimitNofile, RlimitMax},
+			Rlimit{RlimitCore, 0},
+		),
+	)
+	require.Equal(t, ExitOK, res.ExitCode)
+
+	var rl syscall.Rlimit
+	require.NoError(t, syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl))
+	assert.Equal(t, rl.Max, rl.Cur)
+	require.NoError(t, syscall.Getrlimit(syscall.RLIMIT_CORE, &rl))
+	assert.Zero(t, rl.Cur)
+
+	if entries := logs.FilterMessage("starting").All(); assert.Len(t, entries, 1) {
+		fields := entries[0].ContextMap()
+		assert.EqualValues(t, 0, fields["rlimit_core"])
+		assert.Contains(t, fields, "rlimit_nofile")
+		assert.Contains(t, fields, "rlimit_nofile_max")
+	}
+	assert.Zero(t, logs.FilterMessage("set rlimit").Len())
+}
+
+func TestSetRlimit(t *testing.T) {
+	var orig syscall.Rlimit
+	require.NoError(t, syscall.Getrlimit(syscall.RLIMIT_NOFILE, &orig))
+	t.Cleanup(func() { _ = syscall.Setrlimit(syscall.RLIMIT_NOFILE, &orig) })
+
+	// Limits above the hard limit are lowered to it.
+	soft, hard, err := setRlimit(RlimitNofile, RlimitMax)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(orig.Max), hard)
+	assert.Equal(t, hard, soft)
+
+	soft, _, err = setRlimit(RlimitNofile, 128)
+	require.NoError(t, err)
+	assert.EqualValues(t, 128, soft)
+
+	_, _, err = setRlimit(RlimitResource(255), 1)
+	assert.EqualError(t, err, "unknown resource RlimitResource(255)")
+}
+
+func TestWithRlimits_Invalid(t *testing.T) {
+	res := RunE("test", nil, withTestAxiomOptions(), WithRlimits(Rlimit{Resource: RlimitResource(255)}))
+	assert.Equal(t, ExitConfig, res.ExitCode)
+}
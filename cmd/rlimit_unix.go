@@ -0,0 +1,48 @@
+//go:build darwin || linux
+
+package cmd
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// rlimitResources maps the resources to their system specific identifiers.
+var rlimitResources = map[RlimitResource]int{
+	RlimitNofile: syscall.RLIMIT_NOFILE,
+	RlimitCore:   syscall.RLIMIT_CORE,
+	RlimitCPU:    syscall.RLIMIT_CPU,
+	RlimitData:   syscall.RLIMIT_DATA,
+	RlimitFsize:  syscall.RLIMIT_FSIZE,
+	RlimitStack:  syscall.RLIMIT_STACK,
+	RlimitAS:     syscall.RLIMIT_AS,
+}
+
+// setRlimit sets the soft limit of the resource, but not higher than its hard
+// limit or, on darwin, the maximum number of open files per process. It
+// returns the effective soft and hard limit.
+func setRlimit(resource RlimitResource, value uint64) (soft, hard uint64, err error) {
+	id, ok := rlimitResources[resource]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown resource %s", resource)
+	}
+
+	var rl syscall.Rlimit
+	if err = syscall.Getrlimit(id, &rl); err != nil {
+		return 0, 0, err
+	}
+
+	want := rl
+	if limit := maxSoftRlimit(resource, want.Max); value > limit {
+		value = limit
+	}
+	want.Cur = value
+	if want.Cur != rl.Cur {
+		if err = syscall.Setrlimit(id, &want); err != nil {
+			return rl.Cur, rl.Max, err
+		}
+		rl = want
+	}
+
+	return rl.Cur, rl.Max, nil
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/axiomhq/pkg/logctx"
+)
+
+// ComponentError is returned by a `RunFunc` passed to `RunAll()` or
+// `RunAllE()` and records the component that failed.
+type ComponentError struct {
+	// Component is the name of the component that failed.
+	Component string
+	// Err is the error returned by the components `RunFunc`.
+	Err error
+}
+
+// Error implements `error`.
+func (ce *ComponentError) Error() string {
+	return fmt.Sprintf("component %q: %v", ce.Component, ce.Err)
+}
+
+// Unwrap returns the underlying error.
+func (ce *ComponentError) Unwrap() error {
+	return ce.Err
+}
+
+// RunAll is like `Run` but runs the named components concurrently in one
+// application. See `RunAllE()` for details.
+func RunAll(appName string, fns map[string]RunFunc, options ...Option) {
+	if res := RunAllE(appName, fns, options...); res.ExitCode != ExitOK {
+		res.ExitCode.exit()
+	}
+}
+
+// RunAllE is like `RunE` but runs the named components concurrently in one
+// application. Each component gets a child logger named after it and all of
+// them share the Axiom client. The first component that fails cancels the
+// context of the others. Its name is reported by the `Component` field of the
+// result and its error is wrapped in a `*ComponentError`. Components returning
+// `context.Canceled` when the application stops, e.g. on an exit signal, don't
+// fail.
+func RunAllE(appName string, fns map[string]RunFunc, options ...Option) Result {
+	return RunE(appName, runAll(fns), options...)
+}
+
+// runAll returns a `RunFunc` which runs the named components concurrently.
+func runAll(fns map[string]RunFunc) RunFunc {
+	// Start the components in a deterministic order.
+	names := make([]string, 0, len(fns))
+	for name := range fns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return func(ctx context.Context, logger *zap.Logger, client *axiom.Client) error {
+		g, gCtx := errgroup.WithContext(ctx)
+		for _, name := range names {
+			name, fn := name, fns[name]
+			componentLogger := logger.Named(name)
+			componentCtx := logctx.WithLogger(gCtx, componentLogger)
+
+			g.Go(func() error {
+				// Panics are turned into errors, so they don't crash the
+				// process before the other components are shut down.
+				err := callRunFunc(componentCtx, fn, componentLogger, client)
+				if err == nil || (ctx.Err() != nil && errors.Is(err, context.Canceled)) {
+					// Components returning the cancellation of the application
+					// context shut down cleanly.
+					return nil
+				}
+				return &ComponentError{Component: name, Err: err}
+			})
+		}
+		return g.Wait()
+	}
+}
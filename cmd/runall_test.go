@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/axiomhq/pkg/logctx"
+)
+
+func TestRunAll(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	testErr := errors.New("disk full")
+
+	var ingesterClient, compactorClient *axiom.Client
+	res := RunAllE("all-in-one", map[string]RunFunc{
+		"ingester": func(ctx context.Context, logger *zap.Logger, client *axiom.Client) error {
+			ingesterClient = client
+			assert.Equal(t, logger, logctx.From(ctx))
+			logger.Info("running")
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second * 5):
+				require.FailNow(t, "context not cancelled by failed component")
+			}
+			return ctx.Err()
+		},
+		"compactor": func(_ context.Context, _ *zap.Logger, client *axiom.Client) error {
+			compactorClient = client
+			return Error("compaction failed", testErr)
+		},
+	},
+		withTestAxiomOptions(),
+		WithLoggerOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(c, core)
+		})),
+	)
+
+	assert.Equal(t, ExitInternal, res.ExitCode)
+	assert.Equal(t, "compactor", res.Component)
+	assert.True(t, errors.Is(res.Err, testErr))
+	assert.EqualError(t, res.Err, `component "compactor": compaction failed: disk full`)
+
+	if assert.NotNil(t, ingesterClient) {
+		assert.Same(t, ingesterClient, compactorClient)
+	}
+
+	if entries := logs.FilterMessage("running").All(); assert.Len(t, entries, 1) {
+		assert.Equal(t, "all-in-one.ingester", entries[0].LoggerName)
+	}
+	if entries := logs.FilterMessage("compaction failed").All(); assert.Len(t, entries, 1) {
+		assert.Equal(t, "compactor", entries[0].ContextMap()["component"])
+		assert.Equal(t, "disk full", entries[0].ContextMap()["error"])
+	}
+}
+
+func TestRunAll_Panic(t *testing.T) {
+	res := RunAllE("all-in-one", map[string]RunFunc{
+		"query-proxy": func(context.Context, *zap.Logger, *axiom.Client) error {
+			panic("nil map")
+		},
+		"ingester": func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+			<-ctx.Done()
+			return nil
+		},
+	}, withTestAxiomOptions())
+
+	assert.Equal(t, ExitInternal, res.ExitCode)
+	assert.Equal(t, "query-proxy", res.Component)
+	assert.Contains(t, res.Err.Error(), "panic recovered: nil map")
+}
+
+func TestRunAll_OK(t *testing.T) {
+	res := RunAllE("all-in-one", map[string]RunFunc{
+		"a": func(context.Context, *zap.Logger, *axiom.Client) error { return nil },
+		"b": func(context.Context, *zap.Logger, *axiom.Client) error { return nil },
+	}, withTestAxiomOptions())
+
+	assert.Equal(t, ExitOK, res.ExitCode)
+	assert.NoError(t, res.Err)
+	assert.Empty(t, res.Component)
+}
+
+func TestRunAll_Signal(t *testing.T) {
+	res := RunAllE("all-in-one", map[string]RunFunc{
+		"ingester": func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+			p, err := os.FindProcess(os.Getpid())
+			require.NoError(t, err)
+			require.NoError(t, p.Signal(syscall.SIGHUP))
+
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		"compactor": func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+			<-ctx.Done()
+			return fmt.Errorf("compact: %w", ctx.Err())
+		},
+	}, withTestAxiomOptions(), WithExitSignals(syscall.SIGHUP))
+
+	assert.Equal(t, ExitOK, res.ExitCode)
+	assert.NoError(t, res.Err)
+	assert.Empty(t, res.Component)
+	assert.Equal(t, syscall.SIGHUP, res.Signal)
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"context"
+	"math"
+	"os"
+	"runtime/metrics"
+	"sort"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"go.uber.org/zap"
+)
+
+// runtimeMetrics maps the names of the sampled runtime metrics to the field
+// names they are reported under. Histograms are reported as a set of fields
+// using the field name as prefix.
+var runtimeMetrics = map[string]string{
+	"/memory/classes/total:bytes":        "memory_total_bytes",
+	"/memory/classes/heap/objects:bytes": "heap_objects_bytes",
+	"/gc/heap/goal:bytes":                "heap_goal_bytes",
+	"/gc/heap/objects:objects":           "heap_objects",
+	"/gc/cycles/total:gc-cycles":         "gc_cycles",
+	"/sched/goroutines:goroutines":       "goroutines",
+	"/gc/pauses:seconds":                 "gc_pause_seconds",
+	"/sched/pauses/total/gc:seconds":     "gc_pause_seconds",
+	"/sched/latencies:seconds":           "sched_latency_seconds",
+}
+
+// replacedRuntimeMetrics maps the names of deprecated runtime metrics to the
+// names of the metrics replacing them. Deprecated metrics are only sampled if
+// the running Go version doesn't support their replacement.
+var replacedRuntimeMetrics = map[string]string{
+	"/gc/pauses:seconds": "/sched/pauses/total/gc:seconds",
+}
+
+// runtimeStatsReporter periodically samples runtime statistics and reports
+// them as log lines and, optionally, as Axiom events.
+type runtimeStatsReporter struct {
+	logger   *zap.Logger
+	client   *axiom.Client
+	dataset  string
+	interval time.Duration
+
+	samples []metrics.Sample
+	// prevHistograms holds the previously sampled histograms by metric name.
+	// Histograms are cumulative, so only the difference between two samples
+	// describes the distribution during the last interval.
+	prevHistograms map[string]*metrics.Float64Histogram
+}
+
+// newRuntimeStatsReporter creates a new runtime statistics reporter. Events are
+// only ingested if a dataset is given.
+func newRuntimeStatsReporter(logger *zap.Logger, client *axiom.Client, dataset string, interval time.Duration) *runtimeStatsReporter {
+	// Only sample metrics supported by the running Go version.
+	supported := make(map[string]bool)
+	for _, desc := range metrics.All() {
+		if _, ok := runtimeMetrics[desc.Name]; ok {
+			supported[desc.Name] = true
+		}
+	}
+	var samples []metrics.Sample
+	for _, desc := range metrics.All() {
+		if !supported[desc.Name] || supported[replacedRuntimeMetrics[desc.Name]] {
+			continue
+		}
+		samples = append(samples, metrics.Sample{Name: desc.Name})
+	}
+
+	return &runtimeStatsReporter{
+		logger:   logger.Named("runtime"),
+		client:   client,
+		dataset:  dataset,
+		interval: interval,
+
+		samples:        samples,
+		prevHistograms: make(map[string]*metrics.Float64Histogram),
+	}
+}
+
+// run reports runtime statistics periodically. It blocks until the context is
+// marked done.
+func (r *runtimeStatsReporter) run(ctx context.Context) {
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		stats := r.collect()
+
+		fields := make([]zap.Field, 0, len(stats))
+		for _, k := range sortedKeys(stats) {
+			fields = append(fields, zap.Any(k, stats[k]))
+		}
+		r.logger.Info("runtime stats", fields...)
+
+		if r.dataset == "" {
+			continue
+		}
+
+		stats[axiom.TimestampField] = time.Now()
+		ingestCtx, cancel := context.WithTimeout(ctx, r.interval)
+		_, err := r.client.Datasets.IngestEvents(ingestCtx, r.dataset, axiom.IngestOptions{}, stats)
+		cancel()
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("ingest runtime stats", zap.Error(err), zap.String("dataset", r.dataset))
+		}
+	}
+}
+
+// collect samples the runtime statistics.
+func (r *runtimeStatsReporter) collect() axiom.Event {
+	metrics.Read(r.samples)
+
+	stats := make(axiom.Event, len(r.samples)+1)
+	for _, sample := range r.samples {
+		name := runtimeMetrics[sample.Name]
+
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			stats[name] = sample.Value.Uint64()
+		case metrics.KindFloat64:
+			stats[name] = sample.Value.Float64()
+		case metrics.KindFloat64Histogram:
+			hist := sample.Value.Float64Histogram()
+			delta := histogramDelta(r.prevHistograms[sample.Name], hist)
+			r.prevHistograms[sample.Name] = copyHistogram(hist)
+
+			stats[name+"_p50"] = histogramQuantile(delta, 0.5)
+			stats[name+"_p99"] = histogramQuantile(delta, 0.99)
+			stats[name+"_max"] = histogramQuantile(delta, 1)
+		case metrics.KindBad:
+		}
+	}
+
+	if fds, err := openFDs(); err == nil {
+		stats["open_fds"] = fds
+	}
+
+	return stats
+}
+
+// openFDs returns the number of open file descriptors of the process. It is
+// only supported on platforms providing the "/proc" filesystem.
+func openFDs() (int, error) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// copyHistogram returns a deep copy of the histogram. The histograms returned
+// by `metrics.Read()` are reused by subsequent reads.
+func copyHistogram(h *metrics.Float64Histogram) *metrics.Float64Histogram {
+	return &metrics.Float64Histogram{
+		Counts:  append([]uint64(nil), h.Counts...),
+		Buckets: append([]float64(nil), h.Buckets...),
+	}
+}
+
+// histogramDelta returns the histogram of the observations made between the
+// previous and the current histogram.
+func histogramDelta(prev, cur *metrics.Float64Histogram) *metrics.Float64Histogram {
+	delta := copyHistogram(cur)
+	if prev == nil || len(prev.Counts) != len(cur.Counts) {
+		return delta
+	}
+	for i := range delta.Counts {
+		delta.Counts[i] -= prev.Counts[i]
+	}
+	return delta
+}
+
+// histogramQuantile returns the upper bound of the bucket that contains the
+// given quantile. Zero is returned if the histogram holds no observations.
+func histogramQuantile(h *metrics.Float64Histogram, q float64) float64 {
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+
+	threshold := uint64(math.Ceil(q * float64(total)))
+	var cumulative uint64
+	for i, c := range h.Counts {
+		cumulative += c
+		if c == 0 || cumulative < threshold {
+			continue
+		}
+		// Bucket i spans from Buckets[i] to Buckets[i+1]. The outermost
+		// buckets might be unbounded.
+		if upper := h.Buckets[i+1]; !math.IsInf(upper, 1) {
+			return upper
+		}
+		return h.Buckets[i]
+	}
+	return 0
+}
+
+// sortedKeys returns the keys of the event in lexical order.
+func sortedKeys(event axiom.Event) []string {
+	keys := make([]string, 0, len(event))
+	for k := range event {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
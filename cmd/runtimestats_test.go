@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"math"
+	"runtime/metrics"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	h := &metrics.Float64Histogram{
+		Counts:  []uint64{0, 5, 4, 1},
+		Buckets: []float64{math.Inf(-1), 1, 2, 3, math.Inf(1)},
+	}
+
+	assert.EqualValues(t, 2, histogramQuantile(h, 0.5))
+	assert.EqualValues(t, 3, histogramQuantile(h, 0.9))
+	assert.EqualValues(t, 3, histogramQuantile(h, 1))
+
+	prev := copyHistogram(h)
+	h.Counts[1] += 2
+	assert.EqualValues(t, 2, histogramQuantile(histogramDelta(prev, h), 1))
+	assert.Zero(t, histogramQuantile(histogramDelta(h, h), 1))
+}
+
+func TestRun_RuntimeStats(t *testing.T) {
+	axiomOptions, eventCh := withTestIngestServer(t)
+
+	fn := func(context.Context, *zap.Logger, *axiom.Client) error {
+		select {
+		case ingested := <-eventCh:
+			assert.Equal(t, "runtime", ingested.dataset)
+			assert.Contains(t, ingested.event, "goroutines")
+			assert.Contains(t, ingested.event, "heap_objects_bytes")
+			assert.Contains(t, ingested.event, "gc_pause_seconds_p99")
+		case <-time.After(time.Second * 5):
+			require.FailNow(t, "no runtime stats ingested")
+		}
+		return nil
+	}
+
+	res := RunE("test", fn,
+		axiomOptions,
+		WithRuntimeStats(time.Millisecond*10),
+		WithRuntimeStatsDataset("runtime"),
+	)
+	assert.Equal(t, ExitOK, res.ExitCode)
+}
+
+func TestNewRuntimeStatsReporter_ReplacedMetrics(t *testing.T) {
+	r := newRuntimeStatsReporter(zap.NewNop(), nil, "", time.Second)
+
+	names := make(map[string]bool, len(r.samples))
+	for _, sample := range r.samples {
+		names[sample.Name] = true
+	}
+	for deprecated, replacement := range replacedRuntimeMetrics {
+		assert.False(t, names[deprecated] && names[replacement], deprecated)
+	}
+	assert.True(t, names["/sched/pauses/total/gc:seconds"] || names["/gc/pauses:seconds"])
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime/debug"
+
+	"go.uber.org/zap"
+)
+
+// signalHandler is a function called when a signal arrives.
+type signalHandler struct {
+	sig os.Signal
+	fn  func(context.Context)
+}
+
+// signalHandlers calls the handlers registered for non-exit signals. Handlers
+// are called serially, in order of registration.
+type signalHandlers struct {
+	logger   *zap.Logger
+	handlers []signalHandler
+	sigCh    chan os.Signal
+}
+
+// newSignalHandlers creates a dispatcher for the given handlers. Signals are
+// caught from now on, so none get lost before it runs.
+func newSignalHandlers(logger *zap.Logger, handlers []signalHandler) *signalHandlers {
+	h := &signalHandlers{
+		logger:   logger,
+		handlers: handlers,
+		sigCh:    make(chan os.Signal, 1),
+	}
+
+	signals := make([]os.Signal, 0, len(handlers))
+	for _, sh := range handlers {
+		signals = append(signals, sh.sig)
+	}
+	if len(signals) > 0 {
+		signal.Notify(h.sigCh, signals...)
+	}
+
+	return h
+}
+
+// run calls the handlers of arriving signals until the context is marked
+// done.
+func (h *signalHandlers) run(ctx context.Context) {
+	defer signal.Stop(h.sigCh)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-h.sigCh:
+			h.logger.Info("handling signal", zap.Stringer("signal", sig))
+			for _, sh := range h.handlers {
+				if sh.sig == sig {
+					h.call(ctx, sh)
+				}
+			}
+		}
+	}
+}
+
+// call the handler and recover from panics.
+func (h *signalHandlers) call(ctx context.Context, sh signalHandler) {
+	defer func() {
+		if r := recover(); r != nil {
+			h.logger.Error("signal handler panicked",
+				zap.Stringer("signal", sh.sig),
+				zap.String("panic", fmt.Sprint(r)),
+				zap.String("stacktrace", string(debug.Stack())),
+			)
+		}
+	}()
+	sh.fn(ctx)
+}
+
+// validateSignalHandlers makes sure no exit signal has a handler registered,
+// including the profile dump signal, if enabled.
+func validateSignalHandlers(c *config) error {
+	for _, sh := range c.signalHandlers {
+		if containsSignal(c.exitSignals, sh.sig) {
+			return fmt.Errorf("signal %q is an exit signal and can't have a handler", sh.sig)
+		}
+	}
+	if sig := profileDumpSignal; c.profileDumps && sig != nil && containsSignal(c.exitSignals, sig) {
+		return fmt.Errorf("signal %q is an exit signal and can't write profile dumps", sig)
+	}
+	return nil
+}
+
+// hasSignalHandler reports whether a handler is registered for the signal.
+func hasSignalHandler(handlers []signalHandler, sig os.Signal) bool {
+	for _, sh := range handlers {
+		if sh.sig == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// containsSignal reports whether the signal is in the list.
+func containsSignal(signals []os.Signal, sig os.Signal) bool {
+	for _, s := range signals {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRun_SignalHandler(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	callCh := make(chan string, 3)
+	res := RunE("test", func(ctx context.Context, _ *zap.Logger, _ *axiom.Client) error {
+		p, err := os.FindProcess(os.Getpid())
+		require.NoError(t, err)
+		require.NoError(t, p.Signal(syscall.SIGUSR1))
+
+		for _, want := range []string{"first", "second"} {
+			select {
+			case got := <-callCh:
+				assert.Equal(t, want, got)
+			case <-time.After(time.Second * 5):
+				require.FailNow(t, "signal handler not called")
+			}
+		}
+
+		// SIGQUIT is handled by the custom handler instead of dumping the
+		// goroutines.
+		require.NoError(t, p.Signal(syscall.SIGQUIT))
+		select {
+		case got := <-callCh:
+			assert.Equal(t, "quit", got)
+		case <-time.After(time.Second * 5):
+			require.FailNow(t, "signal handler not called")
+		}
+
+		assert.NoError(t, ctx.Err())
+		return nil
+	},
+		withTestAxiomOptions(),
+		WithExitSignals(os.Interrupt, syscall.SIGTERM),
+		WithLoggerOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(c, core)
+		})),
+		WithSignalHandler(syscall.SIGUSR1, func(context.Context) {
+			callCh <- "first"
+			panic("handler failed")
+		}),
+		WithSignalHandler(syscall.SIGUSR1, func(context.Context) {
+			callCh <- "second"
+		}),
+		WithSignalHandler(syscall.SIGQUIT, func(context.Context) {
+			callCh <- "quit"
+		}),
+	)
+	assert.Equal(t, ExitOK, res.ExitCode)
+
+	panics := logs.FilterMessage("signal handler panicked").All()
+	if assert.Len(t, panics, 1) {
+		assert.Equal(t, "handler failed", panics[0].ContextMap()["panic"])
+	}
+	assert.Zero(t, logs.FilterMessage("goroutine dump").Len())
+}
+
+func TestRun_SignalHandler_ExitSignal(t *testing.T) {
+	fn := func(context.Context, *zap.Logger, *axiom.Client) error {
+		require.FailNow(t, "must not be called")
+		return nil
+	}
+
+	res := RunE("test", fn, withTestAxiomOptions(), WithSignalHandler(syscall.SIGHUP, func(context.Context) {}))
+	assert.Equal(t, ExitConfig, res.ExitCode)
+	assert.EqualError(t, res.Err, `signal "hangup" is an exit signal and can't have a handler`)
+
+	res = RunE("test", fn, withTestAxiomOptions(), WithProfileDumps(""), WithExitSignals(syscall.SIGUSR2))
+	assert.Equal(t, ExitConfig, res.ExitCode)
+
+	// Handlers of signals that are no longer exit signals are fine.
+	res = RunE("test", func(context.Context, *zap.Logger, *axiom.Client) error { return nil },
+		withTestAxiomOptions(),
+		WithSignalHandler(syscall.SIGHUP, func(context.Context) {}),
+		WithExitSignals(syscall.SIGTERM),
+	)
+	assert.Equal(t, ExitOK, res.ExitCode)
+}
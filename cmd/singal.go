@@ -1,18 +1,113 @@
 package cmd
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 )
 
-// DefaultExitSignals are the default signals to catch and exit upon.
+// DefaultExitSignals are the default signals to catch and exit upon. Like the
+// Go runtime does, the goroutines are dumped to the log before exiting on
+// SIGQUIT. Leaving SIGQUIT out of the exit signals passed to
+// `WithExitSignals()` dumps the goroutines and keeps the application running
+// instead.
 func DefaultExitSignals() []os.Signal {
 	return []os.Signal{
 		os.Interrupt,
 		os.Kill,
 		syscall.SIGTERM,
-		syscall.SIGQUIT,
 		syscall.SIGINT,
 		syscall.SIGHUP,
+		syscall.SIGQUIT,
 	}
 }
+
+// signalContext is a context that is marked done when one of the signals it
+// listens for arrives. In contrast to the context returned by
+// `signal.NotifyContext()`, it records the signal that arrived and optionally
+// delays marking the context done to allow draining.
+type signalContext struct {
+	context.Context
+
+	cancel     context.CancelFunc
+	ch         chan os.Signal
+	drainCh    chan struct{}
+	drainDelay time.Duration
+
+	mu  sync.Mutex
+	sig os.Signal
+}
+
+// notifyContext returns a copy of the parent context that is marked done when
+// one of the given signals arrives, the returned stop function is called or
+// the parent context is marked done, whichever happens first. If a drain delay
+// is given, the context enters the drain phase when a signal arrives and is
+// only marked done after the delay passed or another signal arrives. The
+// onSignal function, if not nil, is called with the signal before the drain
+// phase begins.
+func notifyContext(parent context.Context, drainDelay time.Duration, onSignal func(os.Signal), signals ...os.Signal) (*signalContext, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	c := &signalContext{
+		Context: ctx,
+
+		cancel:     cancel,
+		ch:         make(chan os.Signal, 1),
+		drainCh:    make(chan struct{}),
+		drainDelay: drainDelay,
+	}
+
+	signal.Notify(c.ch, signals...)
+	go func() {
+		select {
+		case sig := <-c.ch:
+			c.mu.Lock()
+			c.sig = sig
+			c.mu.Unlock()
+		case <-c.Done():
+			return
+		}
+
+		if onSignal != nil {
+			onSignal(c.signal())
+		}
+
+		close(c.drainCh)
+		if c.drainDelay > 0 {
+			t := time.NewTimer(c.drainDelay)
+			defer t.Stop()
+
+			select {
+			case <-t.C:
+			case <-c.ch:
+			case <-c.Done():
+			}
+		}
+		c.cancel()
+	}()
+
+	return c, c.stop
+}
+
+// stop listening for signals and mark the context done.
+func (c *signalContext) stop() {
+	c.cancel()
+	signal.Stop(c.ch)
+}
+
+// draining returns a channel that is closed when a signal arrives and the drain
+// phase begins. The context is marked done once the drain phase is over.
+func (c *signalContext) draining() <-chan struct{} {
+	return c.drainCh
+}
+
+// signal returns the signal that marked the context done. It returns nil if
+// the context wasn't marked done by a signal.
+func (c *signalContext) signal() os.Signal {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.sig
+}
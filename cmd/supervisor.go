@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"go.uber.org/zap"
+
+	xerrors "github.com/axiomhq/pkg/errors"
+)
+
+// Defaults of the `SupervisorPolicy`.
+const (
+	defaultSupervisorInitialBackoff = time.Second
+	defaultSupervisorMaxBackoff     = time.Minute
+	defaultSupervisorStablePeriod   = time.Minute * 5
+)
+
+// SupervisorPolicy configures how the `RunFunc` is restarted by the
+// `WithSupervisor()` option.
+type SupervisorPolicy struct {
+	// InitialBackoff is the delay before the first restart. It doubles with
+	// every restart. Defaults to one second.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum delay before a restart. Defaults to one
+	// minute.
+	MaxBackoff time.Duration
+	// StablePeriod is the time the `RunFunc` must run before failing for the
+	// backoff to be reset to its initial value. Defaults to five minutes.
+	StablePeriod time.Duration
+	// MaxRestarts is the maximum number of restarts within the window. If it
+	// is exceeded, the application gives up and exits with the last error.
+	// Zero means no limit.
+	MaxRestarts int
+	// Window is the time window the restarts are counted in. It must be set if
+	// MaxRestarts is.
+	Window time.Duration
+}
+
+// validate the policy and apply the defaults.
+func (p *SupervisorPolicy) validate() error {
+	switch {
+	case p.InitialBackoff < 0, p.MaxBackoff < 0, p.StablePeriod < 0, p.Window < 0:
+		return errors.New("supervisor durations must not be negative")
+	case p.MaxRestarts < 0:
+		return errors.New("supervisor max restarts must not be negative")
+	case p.MaxRestarts > 0 && p.Window == 0:
+		return errors.New("supervisor window must be set if max restarts is")
+	}
+
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = defaultSupervisorInitialBackoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = defaultSupervisorMaxBackoff
+	}
+	if p.MaxBackoff < p.InitialBackoff {
+		p.MaxBackoff = p.InitialBackoff
+	}
+	if p.StablePeriod == 0 {
+		p.StablePeriod = defaultSupervisorStablePeriod
+	}
+	return nil
+}
+
+// supervise returns a `RunFunc` that restarts the given one when it fails. It
+// is not restarted if it returns without an error, the context is marked done
+// or the error is categorized as permanent or invalid input.
+func (p SupervisorPolicy) supervise(fn RunFunc) RunFunc {
+	return func(ctx context.Context, logger *zap.Logger, client *axiom.Client) error {
+		var (
+			backoff  = p.InitialBackoff
+			restarts []time.Time
+		)
+		for {
+			start := time.Now()
+			err := callRunFunc(ctx, fn, logger, client)
+			if !shouldRestart(ctx, err) {
+				return err
+			}
+			uptime := time.Since(start)
+
+			// A run that was stable for long enough resets the backoff.
+			if uptime >= p.StablePeriod {
+				backoff = p.InitialBackoff
+			}
+
+			// Only count the restarts within the window.
+			now := time.Now()
+			if p.MaxRestarts > 0 {
+				i := 0
+				for i < len(restarts) && now.Sub(restarts[i]) > p.Window {
+					i++
+				}
+				if restarts = restarts[i:]; len(restarts) >= p.MaxRestarts {
+					logger.Error("restart limit reached, giving up",
+						zap.Int("max_restarts", p.MaxRestarts),
+						zap.Duration("window", p.Window),
+					)
+					return err
+				}
+			}
+			restarts = append(restarts, now)
+
+			logger.Warn("restarting",
+				zap.Error(err),
+				zap.Int("restarts", len(restarts)),
+				zap.Duration("uptime", uptime),
+				zap.Duration("backoff", backoff),
+			)
+
+			t := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return err
+			case <-t.C:
+			}
+
+			if backoff *= 2; backoff > p.MaxBackoff {
+				backoff = p.MaxBackoff
+			}
+		}
+	}
+}
+
+// shouldRestart reports whether the `RunFunc` should be restarted after it
+// returned the given error.
+func shouldRestart(ctx context.Context, err error) bool {
+	switch {
+	case err == nil, ctx.Err() != nil:
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+
+	switch xerrors.CategoryOf(err) {
+	case xerrors.Permanent, xerrors.InvalidInput:
+		return false
+	case xerrors.Uncategorized, xerrors.Retryable, xerrors.Unavailable:
+	}
+	return true
+}
+
+// callRunFunc calls the `RunFunc` and turns panics into errors, so they can be
+// restarted from.
+func callRunFunc(ctx context.Context, fn RunFunc, logger *zap.Logger, client *axiom.Client) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = xerrors.FromPanic(r)
+		}
+	}()
+	return fn(ctx, logger, client)
+}
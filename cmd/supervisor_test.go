@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	xerrors "github.com/axiomhq/pkg/errors"
+)
+
+func TestRun_Supervisor(t *testing.T) {
+	var calls int
+	fn := func(context.Context, *zap.Logger, *axiom.Client) error {
+		if calls++; calls < 3 {
+			return errors.New("crashed")
+		}
+		return nil
+	}
+
+	res := RunE("test", fn, withTestAxiomOptions(), WithSupervisor(SupervisorPolicy{
+		InitialBackoff: time.Millisecond,
+	}))
+	assert.Equal(t, ExitOK, res.ExitCode)
+	assert.Equal(t, 3, calls)
+
+	// Invalid policies are rejected.
+	res = RunE("test", fn, withTestAxiomOptions(), WithSupervisor(SupervisorPolicy{MaxRestarts: 1}))
+	assert.Equal(t, ExitConfig, res.ExitCode)
+}
+
+func TestSupervisorPolicy_Backoff(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	p := SupervisorPolicy{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond * 3,
+		StablePeriod:   time.Millisecond * 50,
+		MaxRestarts:    4,
+		Window:         time.Hour,
+	}
+	require.NoError(t, p.validate())
+
+	var calls int
+	err := p.supervise(func(context.Context, *zap.Logger, *axiom.Client) error {
+		// The fourth run is stable and resets the backoff.
+		if calls++; calls == 4 {
+			time.Sleep(p.StablePeriod)
+		}
+		return errors.New("crashed")
+	})(context.Background(), zap.New(core), nil)
+	assert.EqualError(t, err, "crashed")
+	assert.Equal(t, 5, calls)
+
+	var backoffs []time.Duration
+	for _, entry := range logs.FilterMessage("restarting").All() {
+		backoffs = append(backoffs, entry.ContextMap()["backoff"].(time.Duration))
+	}
+	assert.Equal(t, []time.Duration{
+		time.Millisecond,
+		time.Millisecond * 2,
+		time.Millisecond * 3,
+		time.Millisecond,
+	}, backoffs)
+	assert.Equal(t, 1, logs.FilterMessage("restart limit reached, giving up").Len())
+}
+
+func TestSupervisorPolicy_NoRestart(t *testing.T) {
+	p := SupervisorPolicy{InitialBackoff: time.Millisecond}
+	require.NoError(t, p.validate())
+
+	for name, fnErr := range map[string]error{
+		"permanent":     xerrors.WithCategory(errors.New("failed"), xerrors.Permanent),
+		"invalid input": xerrors.WithCategory(errors.New("failed"), xerrors.InvalidInput),
+		"cancelled":     context.Canceled,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var calls int
+			err := p.supervise(func(context.Context, *zap.Logger, *axiom.Client) error {
+				calls++
+				return fnErr
+			})(context.Background(), zap.NewNop(), nil)
+			assert.Equal(t, fnErr, err)
+			assert.Equal(t, 1, calls)
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int
+	err := p.supervise(func(context.Context, *zap.Logger, *axiom.Client) error {
+		calls++
+		cancel()
+		return errors.New("crashed")
+	})(ctx, zap.NewNop(), nil)
+	assert.EqualError(t, err, "crashed")
+	assert.Equal(t, 1, calls)
+}
+
+func TestSupervisorPolicy_Panic(t *testing.T) {
+	p := SupervisorPolicy{InitialBackoff: time.Millisecond}
+	require.NoError(t, p.validate())
+
+	var calls int
+	err := p.supervise(func(context.Context, *zap.Logger, *axiom.Client) error {
+		if calls++; calls == 1 {
+			panic("boom")
+		}
+		return nil
+	})(context.Background(), zap.NewNop(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,29 @@
+# bash completion for ingestd
+#
+# Load it by running: source <(ingestd completion bash)
+
+_ingestd() {
+	local cur="${COMP_WORDS[COMP_CWORD]}"
+	if [[ ${COMP_CWORD} -eq 2 && ${COMP_WORDS[1]} == completion ]]; then
+		COMPREPLY=($(compgen -W "bash zsh fish" -- "${cur}"))
+		return
+	fi
+	case "${COMP_WORDS[COMP_CWORD-1]}" in
+	-addr) return ;;
+	esac
+	if [[ ${cur} == -* ]]; then
+		COMPREPLY=($(compgen -W "-addr -dry-run" -- "${cur}"))
+		return
+	fi
+	local i
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case "${COMP_WORDS[i]}" in
+		-addr) [[ ${COMP_WORDS[i+1]} == = ]] && ((i++)); ((i++)) ;;
+		-* | =) ;;
+		*) return ;;
+		esac
+	done
+	COMPREPLY=($(compgen -W "serve migrate" -- "${cur}"))
+}
+
+complete -o default -F _ingestd ingestd
@@ -0,0 +1,9 @@
+# fish completion for ingestd
+#
+# Load it by running: ingestd completion fish | source
+
+complete -c ingestd -n '__fish_seen_subcommand_from completion' -f -a 'bash zsh fish'
+complete -c ingestd -o addr -r -d 'The address to serve the ingest API on.'
+complete -c ingestd -o dry-run -d 'Validates events [without] ingesting them.'
+complete -c ingestd -n '__fish_use_subcommand' -f -a serve -d 'Serves the ingest API.'
+complete -c ingestd -n '__fish_use_subcommand' -f -a migrate -d 'Migrates the dataset\'s schema: adds missing fields.'
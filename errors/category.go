@@ -0,0 +1,37 @@
+package errors
+
+import "fmt"
+
+// Category describes how an error should be handled.
+type Category uint8
+
+// All available categories.
+const (
+	// Uncategorized errors don't carry a category.
+	Uncategorized Category = iota
+	// Retryable errors are transient and the operation can be retried.
+	Retryable
+	// Permanent errors fail the same way if the operation is retried.
+	Permanent
+	// InvalidInput errors are caused by invalid input or configuration.
+	InvalidInput
+	// Unavailable errors are caused by a dependency that is unavailable.
+	Unavailable
+)
+
+// String returns the string representation of the category.
+func (c Category) String() string {
+	switch c {
+	case Uncategorized:
+		return "uncategorized"
+	case Retryable:
+		return "retryable"
+	case Permanent:
+		return "permanent"
+	case InvalidInput:
+		return "invalid input"
+	case Unavailable:
+		return "unavailable"
+	}
+	return fmt.Sprintf("Category(%d)", c)
+}
@@ -0,0 +1,9 @@
+// Package errors provides errors which carry a stack trace of the site they
+// were created at, a category describing how to handle them and logger fields
+// which accumulate along the chain of wrapped errors. Fields and categories
+// are found even if the error is wrapped again using `fmt.Errorf()` and the
+// "%w" verb.
+//
+// As the package name clashes with the standard library, it is commonly
+// imported as `xerrors`.
+package errors
@@ -0,0 +1,265 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// maxStackDepth is the maximum number of frames captured in a stack trace.
+const maxStackDepth = 32
+
+// Error is an error that carries a message, a category, logger fields and the
+// stack trace of the site it was created at. Any of them can be empty.
+type Error struct {
+	msg      string
+	err      error
+	category Category
+	fields   []zap.Field
+	stack    []uintptr
+}
+
+// New returns an error with the given message and fields. It captures the
+// stack trace of the caller.
+func New(msg string, fields ...zap.Field) error {
+	return &Error{
+		msg:    msg,
+		fields: fields,
+		stack:  callers(),
+	}
+}
+
+// Wrap returns an error that annotates the given error with a message and
+// fields. It captures the stack trace of the caller, unless the chain of the
+// given error already carries one. If the given error is nil, Wrap returns
+// nil.
+func Wrap(err error, msg string, fields ...zap.Field) error {
+	if err == nil {
+		return nil
+	}
+	e := &Error{
+		msg:    msg,
+		err:    err,
+		fields: fields,
+	}
+	if stack(err) == nil {
+		e.stack = callers()
+	}
+	return e
+}
+
+// WithCategory returns an error that annotates the given error with the
+// category. It takes precedence over categories of the errors it wraps. It
+// captures the stack trace of the caller, unless the chain of the given error
+// already carries one. If the given error is nil, WithCategory returns nil.
+func WithCategory(err error, category Category) error {
+	if err == nil {
+		return nil
+	}
+	e := &Error{
+		err:      err,
+		category: category,
+	}
+	if stack(err) == nil {
+		e.stack = callers()
+	}
+	return e
+}
+
+// WithFields returns an error that annotates the given error with logger
+// fields. It captures the stack trace of the caller, unless the chain of the
+// given error already carries one. If the given error is nil, WithFields
+// returns nil.
+func WithFields(err error, fields ...zap.Field) error {
+	if err == nil {
+		return nil
+	}
+	e := &Error{
+		err:    err,
+		fields: fields,
+	}
+	if stack(err) == nil {
+		e.stack = callers()
+	}
+	return e
+}
+
+// Error implements `error`.
+func (e *Error) Error() string {
+	switch {
+	case e.err == nil:
+		return e.msg
+	case e.msg == "":
+		return e.err.Error()
+	}
+	return e.msg + ": " + e.err.Error()
+}
+
+// Unwrap returns the wrapped error, if any.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Format implements `fmt.Formatter`. The "%+v" verb prints the error message
+// followed by the stack trace.
+func (e *Error) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		_, _ = io.WriteString(s, e.Error())
+		if trace := Stack(e); s.Flag('+') && trace != "" {
+			_, _ = io.WriteString(s, "\n"+trace)
+		}
+	case 's':
+		_, _ = io.WriteString(s, e.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+// CategoryOf returns the category of the outermost error in the chain that
+// carries one. Joined errors are searched in order. If no error carries a
+// category, `Uncategorized` is returned.
+func CategoryOf(err error) Category {
+	category := Uncategorized
+	walk(err, func(err error) bool {
+		if e, ok := err.(*Error); ok && e.category != Uncategorized {
+			category = e.category
+			return false
+		}
+		return true
+	})
+	return category
+}
+
+// IsRetryable reports whether the error is categorized as `Retryable` or
+// `Unavailable`.
+func IsRetryable(err error) bool {
+	c := CategoryOf(err)
+	return c == Retryable || c == Unavailable
+}
+
+// Fields returns the fields of all errors in the chain, outermost first.
+// Joined errors are visited in order. If a key is set multiple times, the
+// first field wins.
+func Fields(err error) []zap.Field {
+	var (
+		fields []zap.Field
+		seen   = make(map[string]struct{})
+	)
+	walk(err, func(err error) bool {
+		e, ok := err.(*Error)
+		if !ok {
+			return true
+		}
+		for _, f := range e.fields {
+			if _, ok := seen[f.Key]; ok {
+				continue
+			}
+			seen[f.Key] = struct{}{}
+			fields = append(fields, f)
+		}
+		return true
+	})
+	return fields
+}
+
+// Stack returns the formatted stack trace carried by the innermost error in
+// the chain that carries one. Of joined errors, the first one carrying a stack
+// trace is used. It is empty if no error carries a stack trace.
+func Stack(err error) string {
+	pcs := stack(err)
+	if len(pcs) == 0 {
+		return ""
+	}
+
+	var (
+		sb     strings.Builder
+		frames = runtime.CallersFrames(pcs)
+	)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// stack returns the stack trace carried by the innermost error in the chain
+// that carries one. Of joined errors, the first one carrying a stack trace is
+// used.
+func stack(err error) []uintptr {
+	var pcs []uintptr
+	if e, ok := err.(*Error); ok && e.stack != nil {
+		pcs = e.stack
+	}
+
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		if inner := stack(u.Unwrap()); inner != nil {
+			return inner
+		}
+	case interface{ Unwrap() []error }:
+		for _, joined := range u.Unwrap() {
+			if inner := stack(joined); inner != nil {
+				return inner
+			}
+		}
+	}
+	return pcs
+}
+
+// walk calls the function for all errors in the chain, outermost first, until
+// it returns false. Both, errors wrapping a single error and joined errors
+// wrapping multiple ones, are traversed depth-first. It reports whether the
+// walk completed.
+func walk(err error, fn func(error) bool) bool {
+	if err == nil {
+		return true
+	} else if !fn(err) {
+		return false
+	}
+
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		return walk(u.Unwrap(), fn)
+	case interface{ Unwrap() []error }:
+		for _, joined := range u.Unwrap() {
+			if !walk(joined, fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// callers returns the stack trace of the caller of the function calling it.
+func callers() []uintptr {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(3, pcs[:])
+	return pcs[:n]
+}
+
+// Is reports whether any error in the chain matches the target. It is the
+// same as `errors.Is()` of the standard library.
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
+
+// As finds the first error in the chain that matches the target and, if so,
+// sets the target to that error value and returns true. It is the same as
+// `errors.As()` of the standard library.
+func As(err error, target interface{}) bool {
+	return errors.As(err, target)
+}
+
+// Unwrap returns the result of calling the Unwrap method on the error, if any.
+// It is the same as `errors.Unwrap()` of the standard library.
+func Unwrap(err error) error {
+	return errors.Unwrap(err)
+}
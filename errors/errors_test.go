@@ -0,0 +1,135 @@
+package errors_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	xerrors "github.com/axiomhq/pkg/errors"
+)
+
+func TestWrap(t *testing.T) {
+	assert.NoError(t, xerrors.Wrap(nil, "ignored"))
+	assert.NoError(t, xerrors.WithCategory(nil, xerrors.Retryable))
+	assert.NoError(t, xerrors.WithFields(nil, zap.String("ignored", "")))
+
+	err := xerrors.Wrap(io.EOF, "read event")
+	assert.EqualError(t, err, "read event: EOF")
+	assert.True(t, errors.Is(err, io.EOF))
+
+	err = xerrors.WithCategory(err, xerrors.Retryable)
+	assert.EqualError(t, err, "read event: EOF")
+	assert.True(t, xerrors.Is(err, io.EOF))
+}
+
+func TestCategoryOf(t *testing.T) {
+	assert.Equal(t, xerrors.Uncategorized, xerrors.CategoryOf(io.EOF))
+
+	err := xerrors.WithCategory(io.EOF, xerrors.Unavailable)
+	err = fmt.Errorf("ingest: %w", err)
+	assert.Equal(t, xerrors.Unavailable, xerrors.CategoryOf(err))
+	assert.True(t, xerrors.IsRetryable(err))
+
+	// The outermost category wins.
+	err = xerrors.WithCategory(err, xerrors.Permanent)
+	assert.Equal(t, xerrors.Permanent, xerrors.CategoryOf(err))
+	assert.False(t, xerrors.IsRetryable(err))
+}
+
+func TestFields(t *testing.T) {
+	err := xerrors.New("invalid event", zap.String("dataset", "logs"), zap.Int("line", 1))
+	err = fmt.Errorf("decode: %w", err)
+	err = xerrors.Wrap(err, "ingest", zap.String("tenant", "acme"), zap.Int("line", 2))
+
+	assert.Equal(t, []zap.Field{
+		zap.String("tenant", "acme"),
+		zap.Int("line", 2),
+		zap.String("dataset", "logs"),
+	}, xerrors.Fields(err))
+	assert.Empty(t, xerrors.Fields(io.EOF))
+}
+
+func TestStack(t *testing.T) {
+	assert.Empty(t, xerrors.Stack(io.EOF))
+
+	err := newError()
+	assert.Contains(t, xerrors.Stack(err), "errors_test.newError")
+
+	// Wrapping keeps the original stack trace.
+	err = xerrors.Wrap(fmt.Errorf("wrapped: %w", err), "outer")
+	assert.Contains(t, xerrors.Stack(err), "errors_test.newError")
+	assert.Contains(t, fmt.Sprintf("%+v", err), "outer: wrapped: failed\n")
+	assert.Contains(t, fmt.Sprintf("%+v", err), "errors_test.newError")
+	assert.Equal(t, "outer: wrapped: failed", fmt.Sprintf("%v", err))
+}
+
+func newError() error {
+	return xerrors.New("failed")
+}
+
+func TestStack_Annotations(t *testing.T) {
+	// Annotating an error without a stack trace captures one.
+	err := categorize(io.EOF)
+	assert.Contains(t, xerrors.Stack(err), "errors_test.categorize")
+	assert.Contains(t, xerrors.Stack(xerrors.WithFields(io.EOF)), "errors_test.TestStack_Annotations")
+
+	// Annotating an error with a stack trace keeps it.
+	err = xerrors.WithFields(err, zap.String("dataset", "logs"))
+	assert.Contains(t, xerrors.Stack(err), "errors_test.categorize")
+}
+
+func categorize(err error) error {
+	return xerrors.WithCategory(err, xerrors.Retryable)
+}
+
+// joinError joins multiple errors like `errors.Join()` does.
+type joinError []error
+
+func (je joinError) Error() string {
+	return fmt.Sprint([]error(je))
+}
+
+func (je joinError) Unwrap() []error {
+	return je
+}
+
+func TestJoined(t *testing.T) {
+	err := fmt.Errorf("flush: %w", joinError{
+		io.EOF,
+		xerrors.WithFields(io.ErrUnexpectedEOF, zap.String("dataset", "logs")),
+		xerrors.WithCategory(newError(), xerrors.Unavailable),
+	})
+
+	assert.Equal(t, xerrors.Unavailable, xerrors.CategoryOf(err))
+	assert.Equal(t, []zap.Field{zap.String("dataset", "logs")}, xerrors.Fields(err))
+	assert.Contains(t, xerrors.Stack(err), "errors_test.TestJoined")
+	assert.NotContains(t, xerrors.Stack(err), "errors_test.newError")
+}
+
+func TestCategory_String(t *testing.T) {
+	assert.Equal(t, "invalid input", xerrors.InvalidInput.String())
+	assert.Equal(t, "Category(42)", xerrors.Category(42).String())
+}
+
+func TestFromPanic(t *testing.T) {
+	recovered := func(fn func()) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = xerrors.FromPanic(r)
+			}
+		}()
+		fn()
+		return nil
+	}
+
+	err := recovered(func() { panic("nil map") })
+	assert.Contains(t, err.Error(), "panic recovered: nil map, stacktrace: ")
+	assert.Contains(t, err.Error(), "errors_test.TestFromPanic")
+
+	err = recovered(func() { panic(io.EOF) })
+	assert.True(t, errors.Is(err, io.EOF))
+}
@@ -0,0 +1,21 @@
+package errors_test
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	xerrors "github.com/axiomhq/pkg/errors"
+)
+
+func Example() {
+	err := xerrors.New("connection refused", zap.String("host", "db"))
+	err = xerrors.WithCategory(err, xerrors.Unavailable)
+	err = fmt.Errorf("query users: %w", err)
+
+	fmt.Println(err)
+	fmt.Println(xerrors.CategoryOf(err), xerrors.IsRetryable(err), len(xerrors.Fields(err)))
+	// Output:
+	// query users: connection refused
+	// unavailable true 1
+}
@@ -0,0 +1,17 @@
+package errors
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// FromPanic returns an error describing the value recovered from a panic,
+// including the stack trace of the panicking goroutine. It must be called from
+// the deferred function that recovered the panic. If the recovered value is an
+// error, it is wrapped.
+func FromPanic(r interface{}) error {
+	if err, ok := r.(error); ok {
+		return fmt.Errorf("panic recovered: %w, stacktrace: %s", err, debug.Stack())
+	}
+	return fmt.Errorf("panic recovered: %v, stacktrace: %s", r, debug.Stack())
+}
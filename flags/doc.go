@@ -0,0 +1,6 @@
+// Package flags provides typed feature flags which are configured by
+// environment variables and a local file that is watched for changes. Rollout
+// flags are evaluated per key, e.g. a tenant or dataset, and assign each key a
+// stable bucket, so the same key always gets the same result for the same
+// configuration.
+package flags
@@ -0,0 +1,24 @@
+package flags_test
+
+import (
+	"fmt"
+
+	"github.com/axiomhq/pkg/flags"
+)
+
+func ExampleSet() {
+	s, err := flags.New(flags.WithFile("flags.json"))
+	if err != nil {
+		panic(err)
+	}
+
+	newIngest := s.Bool("new-ingest", false, "Use the new ingest path.")
+	rollout := s.Percentage("compression-rollout", 100, "Datasets using compression.")
+
+	if err = s.Load(); err != nil {
+		panic(err)
+	}
+
+	fmt.Println(newIngest.Enabled(), rollout.Enabled("my-dataset"))
+	// Output: false true
+}
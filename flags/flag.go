@@ -0,0 +1,203 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Type is the type of a flag.
+type Type uint8
+
+// All available flag types.
+const (
+	// TypeBool is a flag that is either on or off.
+	TypeBool Type = iota
+	// TypePercentage is a flag that is on for a percentage of keys.
+	TypePercentage
+	// TypeVariant is a flag that assigns one of multiple weighted string
+	// variants to each key.
+	TypeVariant
+)
+
+// String returns the string representation of the flag type.
+func (t Type) String() string {
+	switch t {
+	case TypeBool:
+		return "bool"
+	case TypePercentage:
+		return "percentage"
+	case TypeVariant:
+		return "variant"
+	}
+	return fmt.Sprintf("Type(%d)", t)
+}
+
+// MarshalText implements `encoding.TextMarshaler`.
+func (t Type) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// value is implemented by all flag types.
+type value interface {
+	// set parses the raw value and, if it is valid, makes it the current one.
+	set(raw string) error
+	// typ returns the type of the flag.
+	typ() Type
+}
+
+// buckets is the number of buckets keys are distributed across. It allows
+// percentages with a precision of two decimal places.
+const buckets = 10000
+
+// bucket returns the stable bucket of the key for the named flag. Including
+// the flag name makes sure that different flags assign different buckets to
+// the same key.
+func bucket(name, key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	return h.Sum32() % buckets
+}
+
+// BoolFlag is a flag that is either on or off. It is safe for concurrent use.
+type BoolFlag struct {
+	v int32
+}
+
+// Enabled reports whether the flag is on.
+func (f *BoolFlag) Enabled() bool {
+	return atomic.LoadInt32(&f.v) == 1
+}
+
+func (f *BoolFlag) set(raw string) error {
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return fmt.Errorf("invalid bool %q", raw)
+	}
+	var i int32
+	if v {
+		i = 1
+	}
+	atomic.StoreInt32(&f.v, i)
+	return nil
+}
+
+func (*BoolFlag) typ() Type { return TypeBool }
+
+// PercentageFlag is a flag that is on for a percentage of keys. It is safe for
+// concurrent use.
+type PercentageFlag struct {
+	name string
+	// v is the number of buckets the flag is on for.
+	v uint32
+}
+
+// Enabled reports whether the flag is on for the given key. For the same
+// configuration, a key always gets the same result. Increasing the percentage
+// keeps the flag on for all keys it was already on for.
+func (f *PercentageFlag) Enabled(key string) bool {
+	return bucket(f.name, key) < atomic.LoadUint32(&f.v)
+}
+
+// Percentage returns the percentage of keys the flag is on for.
+func (f *PercentageFlag) Percentage() float64 {
+	return float64(atomic.LoadUint32(&f.v)) * 100 / buckets
+}
+
+func (f *PercentageFlag) set(raw string) error {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(raw), "%"), 64)
+	if err != nil || v < 0 || v > 100 {
+		return fmt.Errorf("invalid percentage %q, must be between 0 and 100", raw)
+	}
+	atomic.StoreUint32(&f.v, uint32(v*buckets/100))
+	return nil
+}
+
+func (*PercentageFlag) typ() Type { return TypePercentage }
+
+// variant is a string variant with its relative weight.
+type variant struct {
+	name   string
+	weight uint64
+}
+
+// VariantFlag is a flag that assigns one of multiple weighted string variants
+// to each key. It is safe for concurrent use.
+type VariantFlag struct {
+	name string
+	v    atomic.Value // []variant
+}
+
+// Variant returns the variant assigned to the given key. For the same
+// configuration, a key always gets the same variant.
+func (f *VariantFlag) Variant(key string) string {
+	variants := f.v.Load().([]variant)
+	if len(variants) == 1 {
+		return variants[0].name
+	}
+
+	var total uint64
+	for _, v := range variants {
+		total += v.weight
+	}
+
+	b := uint64(bucket(f.name, key))
+	var cum uint64
+	for _, v := range variants {
+		cum += v.weight
+		if b*total < cum*buckets {
+			return v.name
+		}
+	}
+	return variants[len(variants)-1].name
+}
+
+// set parses either a single variant, e.g. "control", which is assigned to all
+// keys or a list of weighted variants, e.g. "control:90,treatment:10".
+func (f *VariantFlag) set(raw string) error {
+	variants, err := parseVariants(raw)
+	if err != nil {
+		return err
+	}
+	f.v.Store(variants)
+	return nil
+}
+
+func (*VariantFlag) typ() Type { return TypeVariant }
+
+func parseVariants(raw string) ([]variant, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) == 1 && !strings.Contains(raw, ":") {
+		if name := strings.TrimSpace(raw); name != "" {
+			return []variant{{name: name, weight: 1}}, nil
+		}
+		return nil, errors.New("variant must not be empty")
+	}
+
+	var (
+		variants = make([]variant, 0, len(parts))
+		total    uint64
+	)
+	for _, part := range parts {
+		i := strings.LastIndexByte(part, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid weighted variant %q, expected \"name:weight\"", part)
+		}
+		name := strings.TrimSpace(part[:i])
+		weight, err := strconv.ParseUint(strings.TrimSpace(part[i+1:]), 10, 32)
+		if name == "" || err != nil {
+			return nil, fmt.Errorf("invalid weighted variant %q, expected \"name:weight\"", part)
+		}
+		variants = append(variants, variant{name: name, weight: weight})
+		total += weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("invalid variants %q, weights must not all be zero", raw)
+	}
+	return variants, nil
+}
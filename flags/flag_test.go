@@ -0,0 +1,67 @@
+package flags
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPercentageFlag(t *testing.T) {
+	f := &PercentageFlag{name: "rollout"}
+	require.NoError(t, f.set("25"))
+	assert.Equal(t, 25.0, f.Percentage())
+
+	var enabled []string
+	for i := 0; i < 10000; i++ {
+		if key := strconv.Itoa(i); f.Enabled(key) {
+			enabled = append(enabled, key)
+		}
+	}
+	assert.InDelta(t, 2500, len(enabled), 200)
+
+	// Increasing the percentage keeps the flag on for all keys it was on for.
+	require.NoError(t, f.set("50%"))
+	for _, key := range enabled {
+		assert.True(t, f.Enabled(key), key)
+	}
+
+	require.NoError(t, f.set("0"))
+	assert.False(t, f.Enabled("tenant"))
+	require.NoError(t, f.set("100"))
+	assert.True(t, f.Enabled("tenant"))
+
+	assert.Error(t, f.set("101"))
+	assert.Error(t, f.set("-1"))
+	assert.Error(t, f.set("half"))
+}
+
+func TestBucket(t *testing.T) {
+	assert.Equal(t, bucket("a", "tenant"), bucket("a", "tenant"))
+	assert.NotEqual(t, bucket("a", "tenant"), bucket("b", "tenant"))
+}
+
+func TestVariantFlag(t *testing.T) {
+	f := &VariantFlag{name: "ingest-path"}
+
+	require.NoError(t, f.set("control"))
+	assert.Equal(t, "control", f.Variant("tenant"))
+
+	require.NoError(t, f.set("control:75, treatment:25"))
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		key := strconv.Itoa(i)
+		counts[f.Variant(key)]++
+		assert.Equal(t, f.Variant(key), f.Variant(key))
+	}
+	assert.InDelta(t, 7500, counts["control"], 200)
+	assert.InDelta(t, 2500, counts["treatment"], 200)
+
+	require.NoError(t, f.set("control:0,treatment:1"))
+	assert.Equal(t, "treatment", f.Variant("tenant"))
+
+	for _, raw := range []string{"", "a:1,b", "a:x", ":1", "a:0,b:0"} {
+		assert.Error(t, f.set(raw), raw)
+	}
+}
@@ -0,0 +1,21 @@
+package flags
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler returns a handler which lists the current values of all flags as
+// JSON. It is meant to be mounted on an admin endpoint.
+func (s *Set) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.Values())
+	})
+}
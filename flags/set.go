@@ -0,0 +1,423 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Source is the source of the current value of a flag.
+type Source uint8
+
+// All available sources, in increasing order of precedence.
+const (
+	// SourceDefault is the default value the flag was defined with.
+	SourceDefault Source = iota
+	// SourceFile is the flag file.
+	SourceFile
+	// SourceEnv is an environment variable.
+	SourceEnv
+)
+
+// String returns the string representation of the source.
+func (s Source) String() string {
+	switch s {
+	case SourceDefault:
+		return "default"
+	case SourceFile:
+		return "file"
+	case SourceEnv:
+		return "env"
+	}
+	return fmt.Sprintf("Source(%d)", s)
+}
+
+// MarshalText implements `encoding.TextMarshaler`.
+func (s Source) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// DefaultEnvPrefix is the default prefix of the environment variables flags
+// are configured by.
+const DefaultEnvPrefix = "FLAG_"
+
+// DefaultPollInterval is the default interval at which the flag file is
+// checked for changes.
+const DefaultPollInterval = time.Second * 10
+
+// An Option modifies the behaviour of a `Set`.
+type Option func(s *Set) error
+
+// WithFile sets the path of the flag file. It is a JSON object mapping flag
+// names to their values, e.g. `{"new-ingest": true, "rollout": 25}`. A missing
+// file is treated like an empty one.
+func WithFile(path string) Option {
+	return func(s *Set) error {
+		s.file = path
+		return nil
+	}
+}
+
+// WithEnvPrefix sets the prefix of the environment variables flags are
+// configured by. It defaults to `DefaultEnvPrefix`.
+func WithEnvPrefix(prefix string) Option {
+	return func(s *Set) error {
+		s.envPrefix = prefix
+		return nil
+	}
+}
+
+// WithPollInterval sets the interval at which the flag file is checked for
+// changes. It defaults to `DefaultPollInterval`.
+func WithPollInterval(interval time.Duration) Option {
+	return func(s *Set) error {
+		if interval <= 0 {
+			return errors.New("poll interval must be greater than zero")
+		}
+		s.pollInterval = interval
+		return nil
+	}
+}
+
+// entry is a flag defined on a set.
+type entry struct {
+	name  string
+	usage string
+	def   string
+	value value
+
+	raw    string
+	source Source
+}
+
+// Set is a set of flags. The value of a flag is taken from the environment
+// variable named after the flag, e.g. "FLAG_NEW_INGEST" for the "new-ingest"
+// flag, from the flag file or from its default, in that order.
+type Set struct {
+	envPrefix    string
+	lookupEnv    func(string) (string, bool)
+	file         string
+	pollInterval time.Duration
+
+	mu         sync.Mutex
+	entries    map[string]*entry
+	fileValues map[string]string
+	fileStat   os.FileInfo
+}
+
+// New creates a new, empty flag set.
+func New(options ...Option) (*Set, error) {
+	s := &Set{
+		envPrefix:    DefaultEnvPrefix,
+		lookupEnv:    os.LookupEnv,
+		pollInterval: DefaultPollInterval,
+
+		entries: make(map[string]*entry),
+	}
+
+	// Apply the supplied options.
+	for _, option := range options {
+		if err := option(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Bool defines a flag that is either on or off. It panics if the name is
+// already defined or the default value is invalid.
+func (s *Set) Bool(name string, def bool, usage string) *BoolFlag {
+	f := new(BoolFlag)
+	s.define(name, strconv.FormatBool(def), usage, f)
+	return f
+}
+
+// Percentage defines a flag that is on for the given percentage of keys. It
+// panics if the name is already defined or the default value is not between 0
+// and 100.
+func (s *Set) Percentage(name string, def float64, usage string) *PercentageFlag {
+	f := &PercentageFlag{name: name}
+	s.define(name, strconv.FormatFloat(def, 'f', -1, 64), usage, f)
+	return f
+}
+
+// Variant defines a flag that assigns one of multiple weighted string variants
+// to each key. A value is either a single variant, e.g. "control", which is
+// assigned to all keys, or a list of weighted variants, e.g.
+// "control:90,treatment:10". It panics if the name is already defined or the
+// default value is invalid.
+func (s *Set) Variant(name string, def string, usage string) *VariantFlag {
+	f := &VariantFlag{name: name}
+	s.define(name, def, usage, f)
+	return f
+}
+
+func (s *Set) define(name, def, usage string, v value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[name]; ok {
+		panic(fmt.Sprintf("flags: flag %q redefined", name))
+	} else if err := v.set(def); err != nil {
+		panic(fmt.Sprintf("flags: flag %q: invalid default: %v", name, err))
+	}
+
+	e := &entry{
+		name:  name,
+		usage: usage,
+		def:   def,
+		value: v,
+
+		raw:    def,
+		source: SourceDefault,
+	}
+	s.entries[name] = e
+
+	// Flags defined after the set was loaded take their value right away. An
+	// invalid value is reported on the next load.
+	_, _ = s.resolve(e)
+}
+
+// EnvName returns the name of the environment variable the named flag is
+// configured by, e.g. "FLAG_NEW_INGEST" for the "new-ingest" flag.
+func (s *Set) EnvName(name string) string {
+	return s.envPrefix + strings.NewReplacer("-", "_", ".", "_").Replace(strings.ToUpper(name))
+}
+
+// File returns the path of the flag file. It is empty if the set has none.
+func (s *Set) File() string {
+	return s.file
+}
+
+// SetEnvLookup sets the function the environment variables of the flags are
+// looked up with. It defaults to `os.LookupEnv()`, which a nil function
+// restores. The flags pick up the variables it returns on the next load.
+func (s *Set) SetEnvLookup(fn func(string) (string, bool)) {
+	if fn == nil {
+		fn = os.LookupEnv
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lookupEnv = fn
+}
+
+// resolve the value of the flag from its sources and apply it. It reports
+// whether the value changed. The lock must be held.
+func (s *Set) resolve(e *entry) (bool, error) {
+	raw, source := e.def, SourceDefault
+	if v, ok := s.fileValues[e.name]; ok {
+		raw, source = v, SourceFile
+	}
+	if v, ok := s.lookupEnv(s.EnvName(e.name)); ok {
+		raw, source = v, SourceEnv
+	}
+
+	if raw == e.raw && source == e.source {
+		return false, nil
+	} else if err := e.value.set(raw); err != nil {
+		return false, fmt.Errorf("flag %q from %s: %w", e.name, source, err)
+	}
+	e.raw, e.source = raw, source
+
+	return true, nil
+}
+
+// Load the values of all flags from their sources. Invalid values are
+// reported, but don't prevent valid ones from being applied.
+func (s *Set) Load() error {
+	var stat os.FileInfo
+	if s.file != "" {
+		stat, _ = os.Stat(s.file)
+	}
+	_, err := s.load(stat)
+	return err
+}
+
+// change describes the change of a flag value.
+type change struct {
+	name     string
+	old, new string
+	source   Source
+}
+
+// load the values of all flags from their sources and return the changed
+// ones.
+func (s *Set) load(stat os.FileInfo) ([]change, error) {
+	var fileValues map[string]string
+	if s.file != "" {
+		var err error
+		if fileValues, err = readFile(s.file); err != nil {
+			// Remember the revision of the file, so the error is only reported
+			// once per revision.
+			s.mu.Lock()
+			s.fileStat = stat
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fileValues = fileValues
+	s.fileStat = stat
+
+	var (
+		changes []change
+		errs    []string
+	)
+	for _, name := range s.names() {
+		e := s.entries[name]
+		old := e.raw
+		if changed, err := s.resolve(e); err != nil {
+			errs = append(errs, err.Error())
+		} else if changed {
+			changes = append(changes, change{name, old, e.raw, e.source})
+		}
+	}
+
+	if len(errs) > 0 {
+		return changes, errors.New(strings.Join(errs, "; "))
+	}
+	return changes, nil
+}
+
+// readFile reads the flag values from the file. A missing file is treated like
+// an empty one.
+func readFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read flag file: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err = json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("parse flag file %q: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for name, v := range raw {
+		switch v := v.(type) {
+		case bool:
+			values[name] = strconv.FormatBool(v)
+		case float64:
+			values[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		case string:
+			values[name] = v
+		default:
+			return nil, fmt.Errorf("parse flag file %q: flag %q: unsupported value %v", path, name, v)
+		}
+	}
+	return values, nil
+}
+
+// Watch the flag file for changes and reload the flags when it changes. It
+// blocks until the context is marked done. Changes and invalid values are
+// logged. It returns immediately if no flag file is configured.
+func (s *Set) Watch(ctx context.Context, logger *zap.Logger) {
+	if s.file == "" {
+		return
+	}
+
+	t := time.NewTicker(s.pollInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		stat, err := os.Stat(s.file)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.Error("stat flag file", zap.Error(err), zap.String("path", s.file))
+			continue
+		} else if !s.fileChanged(stat) {
+			continue
+		}
+
+		changes, err := s.load(stat)
+		for _, c := range changes {
+			logger.Info("flag changed",
+				zap.String("flag", c.name),
+				zap.String("old", c.old),
+				zap.String("new", c.new),
+				zap.Stringer("source", c.source),
+			)
+		}
+		if err != nil {
+			logger.Error("reload flags", zap.Error(err), zap.String("path", s.file))
+		}
+	}
+}
+
+// fileChanged reports whether the flag file changed since it was last read.
+// The stat is nil if the file doesn't exist.
+func (s *Set) fileChanged(stat os.FileInfo) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case stat == nil || s.fileStat == nil:
+		return (stat == nil) != (s.fileStat == nil)
+	case !stat.ModTime().Equal(s.fileStat.ModTime()), stat.Size() != s.fileStat.Size():
+		return true
+	}
+	return false
+}
+
+// Value describes the current value of a flag.
+type Value struct {
+	Name    string `json:"name"`
+	Type    Type   `json:"type"`
+	Value   string `json:"value"`
+	Default string `json:"default"`
+	Source  Source `json:"source"`
+	Usage   string `json:"usage,omitempty"`
+}
+
+// Values returns the current values of all flags, ordered by name.
+func (s *Set) Values() []Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values := make([]Value, 0, len(s.entries))
+	for _, name := range s.names() {
+		e := s.entries[name]
+		values = append(values, Value{
+			Name:    e.name,
+			Type:    e.value.typ(),
+			Value:   e.raw,
+			Default: e.def,
+			Source:  e.source,
+			Usage:   e.usage,
+		})
+	}
+	return values
+}
+
+// names returns the names of all flags in lexical order. The lock must be
+// held.
+func (s *Set) names() []string {
+	names := make([]string, 0, len(s.entries))
+	for name := range s.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
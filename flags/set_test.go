@@ -0,0 +1,179 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSet_Load(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "flags.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"new-ingest": true, "rollout": 10, "path": "b"}`), 0o600))
+	t.Setenv("FLAG_ROLLOUT", "20")
+
+	s, err := New(WithFile(file))
+	require.NoError(t, err)
+
+	newIngest := s.Bool("new-ingest", false, "Use the new ingest path.")
+	rollout := s.Percentage("rollout", 0, "")
+	path := s.Variant("path", "a", "")
+	unset := s.Bool("unset", true, "")
+
+	require.NoError(t, s.Load())
+
+	assert.True(t, newIngest.Enabled())
+	assert.Equal(t, 20.0, rollout.Percentage())
+	assert.Equal(t, "b", path.Variant("tenant"))
+	assert.True(t, unset.Enabled())
+
+	assert.Equal(t, []Value{
+		{Name: "new-ingest", Type: TypeBool, Value: "true", Default: "false", Source: SourceFile, Usage: "Use the new ingest path."},
+		{Name: "path", Type: TypeVariant, Value: "b", Default: "a", Source: SourceFile},
+		{Name: "rollout", Type: TypePercentage, Value: "20", Default: "0", Source: SourceEnv},
+		{Name: "unset", Type: TypeBool, Value: "true", Default: "true", Source: SourceDefault},
+	}, s.Values())
+}
+
+func TestSet_Load_Invalid(t *testing.T) {
+	t.Setenv("FLAG_ENABLED", "maybe")
+
+	s, err := New()
+	require.NoError(t, err)
+
+	enabled := s.Bool("enabled", true, "")
+	other := s.Bool("other", false, "")
+
+	assert.EqualError(t, s.Load(), `flag "enabled" from env: invalid bool "maybe"`)
+	assert.True(t, enabled.Enabled())
+	assert.False(t, other.Enabled())
+}
+
+func TestSet_Define(t *testing.T) {
+	s, err := New()
+	require.NoError(t, err)
+
+	s.Bool("a", false, "")
+	assert.Panics(t, func() { s.Bool("a", false, "") })
+	assert.Panics(t, func() { s.Percentage("b", 200, "") })
+	assert.Equal(t, "FLAG_NEW_INGEST_V2", s.EnvName("new-ingest.v2"))
+}
+
+func TestSet_Watch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "flags.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"enabled": false}`), 0o600))
+
+	s, err := New(WithFile(file), WithPollInterval(time.Millisecond*10))
+	require.NoError(t, err)
+
+	enabled := s.Bool("enabled", false, "")
+	require.NoError(t, s.Load())
+
+	core, logs := observer.New(zap.InfoLevel)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Watch(ctx, zap.New(core))
+	}()
+	defer func() { cancel(); <-done }()
+
+	require.NoError(t, os.WriteFile(file, []byte(`{"enabled": true, "other": 1}`), 0o600))
+	require.Eventually(t, enabled.Enabled, time.Second*5, time.Millisecond*10)
+
+	require.Eventually(t, func() bool { return logs.FilterMessage("flag changed").Len() == 1 }, time.Second, time.Millisecond*10)
+	entry := logs.FilterMessage("flag changed").All()[0]
+	assert.Equal(t, map[string]interface{}{
+		"flag":   "enabled",
+		"old":    "false",
+		"new":    "true",
+		"source": "file",
+	}, entry.ContextMap())
+
+	// Removing the file reverts to the default.
+	require.NoError(t, os.Remove(file))
+	require.Eventually(t, func() bool { return !enabled.Enabled() }, time.Second*5, time.Millisecond*10)
+}
+
+func TestSet_Handler(t *testing.T) {
+	s, err := New()
+	require.NoError(t, err)
+	s.Percentage("rollout", 12.5, "Rollout of the new ingest path.")
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/flags", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var values []map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &values))
+	assert.Equal(t, []map[string]string{{
+		"name":    "rollout",
+		"type":    "percentage",
+		"value":   "12.5",
+		"default": "12.5",
+		"source":  "default",
+		"usage":   "Rollout of the new ingest path.",
+	}}, values)
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/flags", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestSet_Watch_InvalidFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "flags.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"enabled": false}`), 0o600))
+
+	s, err := New(WithFile(file), WithPollInterval(time.Millisecond*5))
+	require.NoError(t, err)
+
+	enabled := s.Bool("enabled", false, "")
+	require.NoError(t, s.Load())
+
+	core, logs := observer.New(zap.InfoLevel)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Watch(ctx, zap.New(core))
+	}()
+	defer func() { cancel(); <-done }()
+
+	// An invalid revision is reported once, no matter how often it is polled.
+	require.NoError(t, os.WriteFile(file, []byte(`{"enabled": tru`), 0o600))
+	require.Eventually(t, func() bool { return logs.FilterMessage("reload flags").Len() == 1 }, time.Second*5, time.Millisecond)
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, 1, logs.FilterMessage("reload flags").Len())
+	assert.False(t, enabled.Enabled())
+
+	// A fixed revision is picked up again.
+	require.NoError(t, os.WriteFile(file, []byte(`{"enabled": true}`), 0o600))
+	require.Eventually(t, enabled.Enabled, time.Second*5, time.Millisecond)
+}
+
+func TestSet_SetEnvLookup(t *testing.T) {
+	t.Setenv("FLAG_ENABLED", "false")
+
+	s, err := New()
+	require.NoError(t, err)
+	enabled := s.Bool("enabled", false, "")
+
+	s.SetEnvLookup(func(name string) (string, bool) {
+		if name == "FLAG_ENABLED" {
+			return "true", true
+		}
+		return "", false
+	})
+	require.NoError(t, s.Load())
+	assert.True(t, enabled.Enabled())
+}
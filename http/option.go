@@ -36,3 +36,15 @@ func WithShutdownTimeout(dur time.Duration) Option {
 		return nil
 	}
 }
+
+// WithRequestLogger injects a per-request logger into the context of each
+// request, which can be read using `logctx.From()`. It is derived from the
+// logger carried by the request context, e.g. the context passed to `Run()`,
+// and logs the request ID, method and path. The request ID is taken from the
+// "X-Request-ID" header or generated and returned in the same header.
+func WithRequestLogger() Option {
+	return func(s *Server) error {
+		s.srv.Handler = requestLogger(s.srv.Handler)
+		return nil
+	}
+}
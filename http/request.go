@@ -0,0 +1,47 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/axiomhq/pkg/logctx"
+)
+
+// RequestIDHeader is the header carrying the ID of a request.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the maximum length of a request ID taken from a
+// request. Longer IDs are replaced by a generated one.
+const maxRequestIDLength = 128
+
+// requestLogger injects a per-request logger into the context of each request
+// before passing it on to the next handler. The logger is derived from the one
+// carried by the request context and logs the request ID, method and path. The
+// request ID is taken from the request or generated and returned to the
+// client.
+func requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := logctx.WithFields(r.Context(),
+			zap.String(logctx.RequestIDKey, id),
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+		)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// newRequestID generates a random request ID.
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
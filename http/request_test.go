@@ -0,0 +1,49 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/axiomhq/pkg/logctx"
+)
+
+func TestRequestLogger(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	ctx := logctx.WithLogger(context.Background(), zap.New(core))
+
+	h := requestLogger(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		logctx.From(r.Context()).Info("handled")
+	}))
+
+	// A request ID given by the client is used.
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/datasets", nil).WithContext(ctx)
+	req.Header.Set(RequestIDHeader, "1234")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, "1234", rec.Header().Get(RequestIDHeader))
+	if assert.Equal(t, 1, logs.Len()) {
+		assert.Equal(t, map[string]interface{}{
+			logctx.RequestIDKey: "1234",
+			"method":            http.MethodGet,
+			"path":              "/api/v1/datasets",
+		}, logs.All()[0].ContextMap())
+	}
+
+	// Missing or overly long request IDs are replaced by generated ones.
+	for _, id := range []string{"", strings.Repeat("a", maxRequestIDLength+1)} {
+		req = httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		req.Header.Set(RequestIDHeader, id)
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		assert.Len(t, rec.Header().Get(RequestIDHeader), 32)
+	}
+}
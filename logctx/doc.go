@@ -0,0 +1,5 @@
+// Package logctx provides helpers to carry a logger in a `context.Context`, so
+// it doesn't need to be passed through deep call stacks explicitly. Fields
+// like a request ID, tenant or dataset can be added along the way and are
+// included in all log entries of the logger read back from the context.
+package logctx
@@ -0,0 +1,53 @@
+package logctx
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// Keys of the fields added by the helpers of this package.
+const (
+	RequestIDKey = "request_id"
+	TenantKey    = "tenant"
+	DatasetKey   = "dataset"
+)
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of the context that carries the logger.
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// From returns the logger carried by the context. If the context doesn't carry
+// a logger, the global logger returned by `zap.L()` is returned.
+func From(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return logger
+	}
+	return zap.L()
+}
+
+// WithFields returns a copy of the context that carries the logger of the
+// parent context with the given fields added.
+func WithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	return WithLogger(ctx, From(ctx).With(fields...))
+}
+
+// WithRequestID returns a copy of the context whose logger logs the given
+// request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return WithFields(ctx, zap.String(RequestIDKey, id))
+}
+
+// WithTenant returns a copy of the context whose logger logs the given tenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return WithFields(ctx, zap.String(TenantKey, tenant))
+}
+
+// WithDataset returns a copy of the context whose logger logs the given
+// dataset.
+func WithDataset(ctx context.Context, dataset string) context.Context {
+	return WithFields(ctx, zap.String(DatasetKey, dataset))
+}
@@ -0,0 +1,38 @@
+package logctx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFrom(t *testing.T) {
+	assert.Equal(t, zap.L(), From(context.Background()))
+
+	logger := zap.NewNop()
+	assert.Equal(t, logger, From(WithLogger(context.Background(), logger)))
+}
+
+func TestWithFields(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	ctx := WithLogger(context.Background(), zap.New(core))
+
+	ctx = WithRequestID(ctx, "1234")
+	ctx = WithTenant(ctx, "acme")
+	ctx = WithDataset(ctx, "logs")
+	ctx = WithFields(ctx, zap.Int("attempt", 2))
+
+	From(ctx).Info("ingested")
+
+	if assert.Equal(t, 1, logs.Len()) {
+		assert.Equal(t, map[string]interface{}{
+			RequestIDKey: "1234",
+			TenantKey:    "acme",
+			DatasetKey:   "logs",
+			"attempt":    int64(2),
+		}, logs.All()[0].ContextMap())
+	}
+}
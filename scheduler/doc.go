@@ -0,0 +1,4 @@
+// Package scheduler provides a scheduler which runs jobs periodically, either
+// at fixed intervals or according to cron expressions. Jobs run until the
+// context passed to the scheduler is marked done.
+package scheduler
@@ -0,0 +1,34 @@
+package scheduler_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/axiomhq/pkg/scheduler"
+)
+
+func ExampleScheduler() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s, err := scheduler.New(zap.NewNop(), scheduler.Job{
+		Name:     "cleanup",
+		Schedule: scheduler.Every(time.Millisecond),
+		Timeout:  time.Second,
+		Overlap:  scheduler.SkipIfRunning,
+		Func: func(context.Context) error {
+			fmt.Println("cleaning up")
+			cancel()
+			return nil
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	// Blocks until the context is cancelled.
+	s.Run(ctx)
+	// Output: cleaning up
+}
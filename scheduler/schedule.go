@@ -0,0 +1,223 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Schedule describes when a job runs.
+type Schedule interface {
+	// Next returns the next activation time after the given time. A zero time
+	// is returned if there is no further activation.
+	Next(time.Time) time.Time
+}
+
+// Every returns a schedule which activates at the given fixed interval.
+func Every(interval time.Duration) Schedule {
+	return everySchedule(interval)
+}
+
+type everySchedule time.Duration
+
+// Next implements `Schedule`.
+func (s everySchedule) Next(t time.Time) time.Time {
+	if s <= 0 {
+		return time.Time{}
+	}
+	return t.Add(time.Duration(s))
+}
+
+// cronMacros are the supported shorthands for common cron expressions.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField describes the valid range of a cron expression field.
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = [5]cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// bitset is a set of small integers.
+type bitset uint64
+
+func (b bitset) has(i int) bool {
+	return b&(1<<uint(i)) != 0
+}
+
+// cronSchedule is a schedule described by a cron expression.
+type cronSchedule struct {
+	minute, hour, dom, month, dow bitset
+
+	// domStar and dowStar indicate that the day of month and the day of week
+	// fields are unrestricted. If both are restricted, a day matches if either
+	// field matches.
+	domStar, dowStar bool
+}
+
+// Cron returns a schedule described by the given cron expression. It supports
+// the standard five fields (minute, hour, day of month, month and day of week)
+// with lists, ranges and steps as well as the "@yearly", "@annually",
+// "@monthly", "@weekly", "@daily", "@midnight" and "@hourly" macros. Times are
+// evaluated in the location of the time passed to `Next()`.
+func Cron(expr string) (Schedule, error) {
+	if macro, ok := cronMacros[strings.TrimSpace(expr)]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q: expected %d fields, got %d", expr, len(cronFields), len(fields))
+	}
+
+	var sets [len(cronFields)]bitset
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday can be written as 0 or 7.
+	if sets[4].has(7) {
+		sets[4] |= 1
+	}
+
+	return &cronSchedule{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// MustCron is like `Cron` but panics if the expression is invalid.
+func MustCron(expr string) Schedule {
+	s, err := Cron(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps.
+func parseCronField(s string, f cronField) (bitset, error) {
+	var set bitset
+	for _, part := range strings.Split(s, ",") {
+		rangePart, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("%s: invalid step in %q", f.name, part)
+			}
+			rangePart = part[:i]
+		}
+
+		lo, hi := f.min, f.max
+		switch i := strings.IndexByte(rangePart, '-'); {
+		case rangePart == "*":
+		case i >= 0:
+			var err error
+			if lo, err = parseCronValue(rangePart[:i], f); err != nil {
+				return 0, err
+			} else if hi, err = parseCronValue(rangePart[i+1:], f); err != nil {
+				return 0, err
+			} else if lo > hi {
+				return 0, fmt.Errorf("%s: invalid range %q", f.name, rangePart)
+			}
+		default:
+			v, err := parseCronValue(rangePart, f)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			// A single value with a step, e.g. "5/15", means "from 5 to the
+			// maximum in steps of 15".
+			if step == 1 {
+				hi = v
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// parseCronValue parses a single value and makes sure it is in range.
+func parseCronValue(s string, f cronField) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid value %q", f.name, s)
+	} else if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s: value %d out of range [%d, %d]", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// cronSearchYears limits the search for the next activation time. Expressions
+// like "0 0 30 2 *" never activate.
+const cronSearchYears = 5
+
+// Next implements `Schedule`.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	yearLimit := t.Year() + cronSearchYears
+
+	// Advance the time field by field, from the month down to the minute. When
+	// a field wraps around, the search starts over from the month.
+	for t.Year() <= yearLimit {
+		if !s.month.has(int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.hour.has(t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if !s.minute.has(t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches reports whether the day of the given time matches the schedule.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom.has(t.Day())
+	dowMatch := s.dow.has(int(t.Weekday()))
+
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
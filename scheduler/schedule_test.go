@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvery(t *testing.T) {
+	now := time.Now()
+
+	assert.Equal(t, now.Add(time.Minute), Every(time.Minute).Next(now))
+	assert.True(t, Every(0).Next(now).IsZero())
+}
+
+func TestCron(t *testing.T) {
+	// Saturday, 2021-10-16 10:42:13 UTC.
+	now := time.Date(2021, 10, 16, 10, 42, 13, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2021, 10, 16, 10, 43, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 10, 16, 10, 45, 0, 0, time.UTC)},
+		{"5/15 * * * *", time.Date(2021, 10, 16, 10, 50, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2021, 10, 16, 11, 0, 0, 0, time.UTC)},
+		{"30 9 * * *", time.Date(2021, 10, 17, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1-5", time.Date(2021, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, 10, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,20 * 1", time.Date(2021, 10, 18, 12, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, 10, 16, 11, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := Cron(tt.expr)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, s.Next(now))
+		})
+	}
+}
+
+func TestCron_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+	} {
+		_, err := Cron(expr)
+		assert.Error(t, err, expr)
+	}
+
+	assert.Panics(t, func() { MustCron("invalid") })
+}
@@ -0,0 +1,238 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	xerrors "github.com/axiomhq/pkg/errors"
+	"github.com/axiomhq/pkg/workgate"
+)
+
+// OverlapPolicy describes what happens if a job is due while its previous run
+// is still in progress.
+type OverlapPolicy uint8
+
+// All available overlap policies.
+const (
+	// NoOverlap delays the run until the previous run finished. Multiple
+	// missed activations result in a single run.
+	NoOverlap OverlapPolicy = iota
+	// SkipIfRunning skips the run if the previous run is still in progress.
+	SkipIfRunning
+)
+
+// String returns the string representation of the overlap policy.
+func (op OverlapPolicy) String() string {
+	switch op {
+	case NoOverlap:
+		return "no-overlap"
+	case SkipIfRunning:
+		return "skip-if-running"
+	}
+	return fmt.Sprintf("OverlapPolicy(%d)", op)
+}
+
+// Job is a function that runs periodically according to its schedule.
+type Job struct {
+	// Name of the job. Must be unique within a scheduler.
+	Name string
+	// Schedule describes when the job runs.
+	Schedule Schedule
+	// Func is the function to run. The context passed to it is marked done
+	// when the timeout is exceeded or the scheduler is stopped.
+	Func func(context.Context) error
+
+	// Timeout of a single run. Zero means no timeout.
+	Timeout time.Duration
+	// Jitter is the maximum random delay added to each activation. It helps to
+	// spread the load of many instances running the same job.
+	Jitter time.Duration
+	// Overlap is the policy applied if a job is due while its previous run is
+	// still in progress.
+	Overlap OverlapPolicy
+	// Gate optionally limits the number of concurrent runs. It can be shared
+	// between jobs to limit the concurrency across all of them. With the
+	// `NoOverlap` policy, a run waits for the gate to open or the scheduler to
+	// stop. With the `SkipIfRunning` policy, a run is skipped if the gate is
+	// full.
+	Gate *workgate.WorkGate
+}
+
+// Scheduler runs jobs according to their schedules.
+type Scheduler struct {
+	logger *zap.Logger
+	jobs   []Job
+}
+
+// New creates a new scheduler for the given jobs. An error is returned if a
+// job is invalid or job names are not unique.
+func New(logger *zap.Logger, jobs ...Job) (*Scheduler, error) {
+	names := make(map[string]struct{}, len(jobs))
+	for _, job := range jobs {
+		switch {
+		case job.Name == "":
+			return nil, errors.New("job name must not be empty")
+		case job.Schedule == nil:
+			return nil, fmt.Errorf("job %q: schedule must not be nil", job.Name)
+		case job.Func == nil:
+			return nil, fmt.Errorf("job %q: function must not be nil", job.Name)
+		case job.Timeout < 0 || job.Jitter < 0:
+			return nil, fmt.Errorf("job %q: timeout and jitter must not be negative", job.Name)
+		}
+		if _, ok := names[job.Name]; ok {
+			return nil, fmt.Errorf("job %q: duplicate name", job.Name)
+		}
+		names[job.Name] = struct{}{}
+	}
+
+	return &Scheduler{
+		logger: logger,
+		jobs:   jobs,
+	}, nil
+}
+
+// Run all jobs according to their schedules. It blocks until the context is
+// marked done and all runs in progress returned.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			s.schedule(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+}
+
+// schedule runs the job according to its schedule until the context is marked
+// done.
+func (s *Scheduler) schedule(ctx context.Context, job Job) {
+	var (
+		logger  = s.logger.With(zap.String("job", job.Name))
+		runs    sync.WaitGroup
+		running int32
+
+		// Seed the jitter per job, so multiple instances running the same job
+		// don't end up with the same delays.
+		rng = rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec // Jitter doesn't need to be cryptographically secure.
+	)
+	defer runs.Wait()
+
+	t := time.NewTimer(0)
+	if !t.Stop() {
+		<-t.C
+	}
+	defer t.Stop()
+
+	next := job.Schedule.Next(time.Now())
+	for {
+		if next.IsZero() {
+			logger.Warn("job has no further activations")
+			return
+		}
+
+		delay := time.Until(next)
+		if job.Jitter > 0 {
+			delay += time.Duration(rng.Int63n(int64(job.Jitter)))
+		}
+		t.Reset(delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		switch job.Overlap {
+		case NoOverlap:
+			s.runGated(ctx, logger, job, true)
+		case SkipIfRunning:
+			if !atomic.CompareAndSwapInt32(&running, 0, 1) {
+				logger.Warn("job skipped, previous run still in progress")
+				break
+			}
+			runs.Add(1)
+			go func() {
+				defer runs.Done()
+				defer atomic.StoreInt32(&running, 0)
+				s.runGated(ctx, logger, job, false)
+			}()
+		default:
+			logger.Error("job skipped, unknown overlap policy", zap.Stringer("policy", job.Overlap))
+		}
+
+		// Activations missed while the job was running are collapsed into a
+		// single, immediate run.
+		if next = job.Schedule.Next(next); !next.IsZero() && next.Before(time.Now()) {
+			next = time.Now()
+		}
+	}
+}
+
+// runGated runs the job through its work gate, if it has one configured. If
+// the gate is full, the run either waits for it to open or is skipped.
+func (s *Scheduler) runGated(ctx context.Context, logger *zap.Logger, job Job, wait bool) {
+	if job.Gate == nil {
+		s.run(ctx, logger, job)
+		return
+	}
+
+	task := func() (interface{}, error) {
+		s.run(ctx, logger, job)
+		return nil, nil
+	}
+
+	var err error
+	if wait {
+		_, err = job.Gate.DoContext(ctx, task)
+	} else {
+		_, err = job.Gate.TryDo(task)
+	}
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// The scheduler stopped while waiting for the gate.
+	case errors.Is(err, workgate.ErrGateFull):
+		logger.Warn("job skipped, work gate full")
+	case err != nil:
+		logger.Error("job skipped", zap.Error(err))
+	}
+}
+
+// run the job once and log the outcome.
+func (s *Scheduler) run(ctx context.Context, logger *zap.Logger, job Job) {
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	logger.Info("job started")
+
+	err := call(ctx, job.Func)
+
+	fields := []zap.Field{zap.Duration("duration", time.Since(start))}
+	if err != nil {
+		logger.Error("job failed", append(fields, zap.Error(err))...)
+		return
+	}
+	logger.Info("job finished", fields...)
+}
+
+// call the function and turn panics into errors.
+func call(ctx context.Context, fn func(context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = xerrors.FromPanic(r)
+		}
+	}()
+	return fn(ctx)
+}
@@ -0,0 +1,140 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/axiomhq/pkg/workgate"
+)
+
+func TestNew(t *testing.T) {
+	fn := func(context.Context) error { return nil }
+
+	_, err := New(zap.NewNop(), Job{Name: "a", Schedule: Every(time.Second), Func: fn})
+	assert.NoError(t, err)
+
+	_, err = New(zap.NewNop(), Job{Schedule: Every(time.Second), Func: fn})
+	assert.EqualError(t, err, "job name must not be empty")
+
+	_, err = New(zap.NewNop(), Job{Name: "a", Func: fn})
+	assert.EqualError(t, err, `job "a": schedule must not be nil`)
+
+	_, err = New(zap.NewNop(), Job{Name: "a", Schedule: Every(time.Second)})
+	assert.EqualError(t, err, `job "a": function must not be nil`)
+
+	_, err = New(zap.NewNop(),
+		Job{Name: "a", Schedule: Every(time.Second), Func: fn},
+		Job{Name: "a", Schedule: Every(time.Second), Func: fn},
+	)
+	assert.EqualError(t, err, `job "a": duplicate name`)
+}
+
+func TestScheduler(t *testing.T) {
+	var runs, timeouts int32
+
+	s, err := New(zap.NewNop(),
+		Job{
+			Name:     "count",
+			Schedule: Every(time.Millisecond * 10),
+			Func: func(context.Context) error {
+				atomic.AddInt32(&runs, 1)
+				return errors.New("failed runs are rescheduled as well")
+			},
+		},
+		Job{
+			Name:     "timeout",
+			Schedule: Every(time.Millisecond * 10),
+			Timeout:  time.Millisecond,
+			Func: func(ctx context.Context) error {
+				<-ctx.Done()
+				atomic.AddInt32(&timeouts, 1)
+				return ctx.Err()
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+
+	s.Run(ctx)
+
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&runs), int32(5))
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&timeouts), int32(5))
+}
+
+func TestScheduler_SkipIfRunning(t *testing.T) {
+	var (
+		running, maxRunning int32
+		runs                int32
+	)
+
+	fn := func(ctx context.Context) error {
+		if n := atomic.AddInt32(&running, 1); n > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, n)
+		}
+		defer atomic.AddInt32(&running, -1)
+
+		atomic.AddInt32(&runs, 1)
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Millisecond * 50):
+		}
+		return nil
+	}
+
+	// Both jobs share a gate which only allows a single run at a time.
+	gate := workgate.New(1)
+	s, err := New(zap.NewNop(),
+		Job{Name: "a", Schedule: Every(time.Millisecond * 5), Func: fn, Overlap: SkipIfRunning, Gate: gate},
+		Job{Name: "b", Schedule: Every(time.Millisecond * 5), Func: fn, Overlap: SkipIfRunning, Gate: gate},
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+
+	s.Run(ctx)
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&maxRunning))
+	assert.Zero(t, atomic.LoadInt32(&running))
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&runs), int32(2))
+}
+
+func TestScheduler_WaitForGateStops(t *testing.T) {
+	// The gate is occupied for longer than the scheduler runs.
+	gate := workgate.New(1)
+	release := make(chan struct{})
+	defer close(release)
+	require.NoError(t, gate.DoAsync(func() { <-release }, nil))
+
+	s, err := New(zap.NewNop(), Job{
+		Name:     "blocked",
+		Schedule: Every(time.Millisecond),
+		Func:     func(context.Context) error { return nil },
+		Gate:     gate,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		require.FailNow(t, "scheduler blocked by work gate")
+	}
+}
@@ -73,6 +73,37 @@ func (wg *WorkGate) Do(task func() (interface{}, error)) (interface{}, error) {
 	return nil, ErrGateClosed
 }
 
+// DoContext is like Do, but stops waiting for the gate if the context is
+// cancelled. In that case ctx.Err() is returned and the task is not run.
+func (wg *WorkGate) DoContext(ctx context.Context, task func() (interface{}, error)) (interface{}, error) {
+	if err := wg.enterContext(ctx); err != nil {
+		return nil, err
+	}
+	defer wg.Leave()
+	return task()
+}
+
+// enterContext is like Enter, but stops waiting if the context is cancelled.
+func (wg *WorkGate) enterContext(ctx context.Context) (err error) {
+	// Handle wg.q <- struct{}{} panic
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = ErrGateClosed
+		}
+	}()
+
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case wg.q <- struct{}{}: // same as wg.Enter()
+		return nil
+	}
+}
+
 // TryDo is like Do, but returns an error if the gate is full.
 func (wg *WorkGate) TryDo(task func() (interface{}, error)) (res interface{}, err error) {
 	defer func() {
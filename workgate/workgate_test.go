@@ -274,3 +274,35 @@ func TestResourceGateDoAsyncContextGateClosed(t *testing.T) {
 	)
 	wg.Wait()
 }
+
+func TestWorkGateDoContext(t *testing.T) {
+	gate := New(1)
+	defer gate.Close()
+
+	res, err := gate.DoContext(context.Background(), func() (interface{}, error) {
+		return "foo", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", res)
+
+	wait := make(chan struct{})
+	defer close(wait)
+	assert.NoError(t, gate.DoAsync(func() {
+		<-wait
+	}, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	_, err = gate.DoContext(ctx, func() (interface{}, error) {
+		assert.Fail(t, "must not be called")
+		return nil, nil
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	gate.Close()
+	_, err = gate.DoContext(context.Background(), func() (interface{}, error) {
+		return nil, nil
+	})
+	assert.Equal(t, ErrGateClosed, err)
+}